@@ -0,0 +1,83 @@
+// Package apitest fakes the GitHub API for the tests of api and its callers
+package apitest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// FakeGitHub is a RoundTripper that answers requests with canned JSON bodies
+// keyed by method and path, e.g. "GET /repos/o/r/labels", and records every
+// request it gets. A key with the query string, e.g.
+// "GET /repos/o/r/labels?page=2", wins over one without. GETs without a
+// response get a 404, other requests an empty 204.
+type FakeGitHub struct {
+	Responses map[string]string
+	// Pages holds bodies answered in turn to the requests with a key, such
+	// as the pages of a GraphQL connection. The last one is repeated once
+	// they run out. Pages win over Responses.
+	Pages map[string][]string
+	// Headers holds the headers sent with a response, by the same keys
+	Headers map[string]http.Header
+
+	mu       sync.Mutex
+	requests []string
+}
+
+// NewFakeGitHub returns a FakeGitHub answering with responses
+func NewFakeGitHub(responses map[string]string) *FakeGitHub {
+	return &FakeGitHub{Responses: responses, Pages: map[string][]string{}, Headers: map[string]http.Header{}}
+}
+
+func (f *FakeGitHub) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, strings.TrimSpace(req.Method+" "+req.URL.Path+" "+body))
+
+	key := req.Method + " " + req.URL.Path
+	if f.answers(key + "?" + req.URL.RawQuery) {
+		key += "?" + req.URL.RawQuery
+	}
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Request: req}
+	data, ok := f.Responses[key]
+	if pages := f.Pages[key]; len(pages) > 0 {
+		data, ok = pages[0], true
+		if len(pages) > 1 {
+			f.Pages[key] = pages[1:]
+		}
+	}
+	switch {
+	case !ok && req.Method == "GET":
+		resp.StatusCode, data = http.StatusNotFound, `{"message":"Not Found"}`
+	case !ok:
+		resp.StatusCode = http.StatusNoContent
+	}
+	for name, values := range f.Headers[key] {
+		resp.Header[name] = values
+	}
+	resp.Body = ioutil.NopCloser(strings.NewReader(data))
+	return resp, nil
+}
+
+// answers reports whether there is a response for key
+func (f *FakeGitHub) answers(key string) bool {
+	_, ok := f.Responses[key]
+	return ok || len(f.Pages[key]) > 0
+}
+
+// Requests returns the requests made so far as "METHOD /path body", and
+// forgets them
+func (f *FakeGitHub) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
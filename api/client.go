@@ -2,25 +2,33 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"reflect"
 	"regexp"
 	"strings"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/henvic/httpretty"
 )
 
-var Org string
-
 // ClientOption represents an argument to NewClient
 type ClientOption = func(http.RoundTripper) http.RoundTripper
 
-// NewClient initializes a Client
+// NewClient initializes a Client for github.com
 func NewClient(opts ...ClientOption) *Client {
+	return NewClientForHost("github.com", opts...)
+}
+
+// NewClientForHost initializes a Client for github.com or a GitHub Enterprise
+// Server host. The host may include a scheme, e.g. http://127.0.0.1:8080 for
+// a local stand-in, otherwise https is used.
+func NewClientForHost(host string, opts ...ClientOption) *Client {
 	tr := http.DefaultTransport
 	for _, opt := range opts {
 		tr = opt(tr)
@@ -28,9 +36,24 @@ func NewClient(opts ...ClientOption) *Client {
 
 	http := &http.Client{Transport: tr}
 	client := &Client{http: http}
+	client.restURL, client.graphQLURL = apiURLs(host)
 	return client
 }
 
+// apiURLs derives the REST and GraphQL endpoints of a host
+func apiURLs(host string) (string, string) {
+	scheme := "https"
+	if i := strings.Index(host, "://"); i >= 0 {
+		scheme, host = host[:i], host[i+3:]
+	}
+	host = strings.TrimSuffix(host, "/")
+
+	if host == "" || host == "github.com" || host == "api.github.com" {
+		return scheme + "://api.github.com/", scheme + "://api.github.com/graphql"
+	}
+	return fmt.Sprintf("%s://%s/api/v3/", scheme, host), fmt.Sprintf("%s://%s/api/graphql", scheme, host)
+}
+
 // AddHeader turns a RoundTripper into one that adds a request header
 func AddHeader(name, value string) ClientOption {
 	return func(tr http.RoundTripper) http.RoundTripper {
@@ -68,7 +91,41 @@ func (tr funcTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 
 // Client facilitates making HTTP requests to the GitHub API
 type Client struct {
-	http *http.Client
+	http       *http.Client
+	restURL    string
+	graphQLURL string
+
+	// logger receives the retry and rate limit warnings of the requests
+	// made through this client, the standard logger when nil
+	logger log.FieldLogger
+}
+
+// WithLogger returns a copy of the client that sends the retry and rate limit
+// warnings of its requests to logger, so they are grouped with the output of
+// the repository the requests are made for
+func (c *Client) WithLogger(logger log.FieldLogger) *Client {
+	client := *c
+	client.logger = logger
+	return &client
+}
+
+type loggerKey struct{}
+
+// newRequest creates a request carrying the logger of the client
+func (c Client) newRequest(method string, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil || c.logger == nil {
+		return req, err
+	}
+	return req.WithContext(context.WithValue(req.Context(), loggerKey{}, c.logger)), nil
+}
+
+// requestLogger returns the logger of the client that made the request
+func requestLogger(req *http.Request) log.FieldLogger {
+	if logger, ok := req.Context().Value(loggerKey{}).(log.FieldLogger); ok {
+		return logger
+	}
+	return log.StandardLogger()
 }
 
 type graphQLResponse struct {
@@ -76,6 +133,13 @@ type graphQLResponse struct {
 	Errors []GraphQLError
 }
 
+// PageInfo is the pagination state of a GraphQL connection. Queries for a
+// connection take a $cursor variable and repeat until HasNextPage is false.
+type PageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
 // GraphQLError is a single error returned in a GraphQL response
 type GraphQLError struct {
 	Type    string
@@ -98,13 +162,13 @@ func (gr GraphQLErrorResponse) Error() string {
 
 // GraphQL performs a GraphQL request and parses the response
 func (c Client) GraphQL(query string, variables map[string]interface{}, data interface{}) error {
-	url := "https://api.github.com/graphql"
+	url := c.graphQLURL
 	reqBody, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+	req, err := c.newRequest("POST", url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return err
 	}
@@ -120,42 +184,74 @@ func (c Client) GraphQL(query string, variables map[string]interface{}, data int
 	return handleResponse(resp, data)
 }
 
-// REST performs a REST request and parses the response.
+// REST performs a REST request and parses the response. When data points to
+// a slice, the pages listed in the Link header of a GET response are fetched
+// and appended to it as well.
 func (c Client) REST(method string, p string, body io.Reader, data interface{}) error {
-	url := "https://api.github.com/" + p
-	req, err := http.NewRequest(method, url, body)
+	url := c.restURL + p
+
+	v := reflect.ValueOf(data)
+	paginate := method == "GET" && v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice
+
+	next, err := c.rest(method, url, body, data)
+	for err == nil && paginate && next != "" {
+		page := reflect.New(v.Elem().Type())
+		next, err = c.rest(method, next, &bytes.Buffer{}, page.Interface())
+		v.Elem().Set(reflect.AppendSlice(v.Elem(), page.Elem()))
+	}
+	return err
+}
+
+// rest performs a single REST request and returns the URL of the next page
+func (c Client) rest(method string, url string, body io.Reader, data interface{}) (string, error) {
+	req, err := c.newRequest(method, url, body)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	success := resp.StatusCode >= 200 && resp.StatusCode < 300
 	if !success {
-		return handleHTTPError(resp)
+		return "", handleHTTPError(resp)
 	}
 
 	if resp.StatusCode == http.StatusNoContent {
-		return nil
+		return "", nil
 	}
 
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return "", err
+	}
+	// Some endpoints, such as creating a secret, answer 201 without a body
+	if len(b) == 0 {
+		return "", nil
 	}
 
 	err = json.Unmarshal(b, &data)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return nextPage(resp), nil
+}
+
+var linkNextRE = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextPage returns the URL of the next page from the Link header, if any
+func nextPage(resp *http.Response) string {
+	m := linkNextRE.FindStringSubmatch(resp.Header.Get("Link"))
+	if m == nil {
+		return ""
+	}
+	return m[1]
 }
 
 func handleResponse(resp *http.Response, data interface{}) error {
@@ -182,6 +278,17 @@ func handleResponse(resp *http.Response, data interface{}) error {
 	return nil
 }
 
+// HTTPError is returned for REST and GraphQL responses with a non 2xx status
+type HTTPError struct {
+	StatusCode int
+	URL        string
+	Message    string
+}
+
+func (err HTTPError) Error() string {
+	return fmt.Sprintf("http error, '%s' failed (%d): '%s'", err.URL, err.StatusCode, err.Message)
+}
+
 func handleHTTPError(resp *http.Response) error {
 	var message string
 	var parsedBody struct {
@@ -198,10 +305,37 @@ func handleHTTPError(resp *http.Response) error {
 		message = parsedBody.Message
 	}
 
-	return fmt.Errorf("http error, '%s' failed (%d): '%s'", resp.Request.URL, resp.StatusCode, message)
+	return &HTTPError{StatusCode: resp.StatusCode, URL: resp.Request.URL.String(), Message: message}
+}
+
+// IsNotFound reports whether err is a 404 response
+func IsNotFound(err error) bool {
+	httpErr, ok := err.(*HTTPError)
+	return ok && httpErr.StatusCode == http.StatusNotFound
+}
+
+// Header performs a GET request and returns the response headers
+func (c Client) Header(p string) (http.Header, error) {
+	req, err := c.newRequest("GET", c.restURL+p, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	success := resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !success {
+		return nil, handleHTTPError(resp)
+	}
+	return resp.Header, nil
 }
 
-// VerboseLog enables request/response logging within a RoundTripper
+// VerboseLog enables request/response logging within a RoundTripper. The
+// values of secret fields in JSON bodies are masked.
 func VerboseLog(out io.Writer, logTraffic bool) ClientOption {
 	logger := &httpretty.Logger{
 		Time:           true,
@@ -210,7 +344,7 @@ func VerboseLog(out io.Writer, logTraffic bool) ClientOption {
 		RequestBody:    logTraffic,
 		ResponseHeader: logTraffic,
 		ResponseBody:   logTraffic,
-		Formatters:     []httpretty.Formatter{&httpretty.JSONFormatter{}},
+		Formatters:     []httpretty.Formatter{&redactFormatter{}},
 	}
 	logger.SetOutput(out)
 	logger.SetBodyFilter(func(h http.Header) (skip bool, err error) {
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPIURLs(t *testing.T) {
+	tests := []struct {
+		host    string
+		rest    string
+		graphQL string
+	}{
+		{"", "https://api.github.com/", "https://api.github.com/graphql"},
+		{"github.com", "https://api.github.com/", "https://api.github.com/graphql"},
+		{"api.github.com", "https://api.github.com/", "https://api.github.com/graphql"},
+		{"github.example.com", "https://github.example.com/api/v3/", "https://github.example.com/api/graphql"},
+		{"github.example.com/", "https://github.example.com/api/v3/", "https://github.example.com/api/graphql"},
+		{"https://github.example.com", "https://github.example.com/api/v3/", "https://github.example.com/api/graphql"},
+		{"http://127.0.0.1:8080", "http://127.0.0.1:8080/api/v3/", "http://127.0.0.1:8080/api/graphql"},
+	}
+	for _, tt := range tests {
+		rest, graphQL := apiURLs(tt.host)
+		if rest != tt.rest || graphQL != tt.graphQL {
+			t.Errorf("apiURLs(%q) = %s, %s, want %s, %s", tt.host, rest, graphQL, tt.rest, tt.graphQL)
+		}
+	}
+}
+
+func TestRESTPages(t *testing.T) {
+	client, fake := newFakeClient(map[string]string{
+		"GET /repos/o/r/labels":             `[{"name":"bug"},{"name":"docs"}]`,
+		"GET /repositories/1/labels?page=2": `[{"name":"triage"}]`,
+		"GET /repos/o/r":                    `{"name":"r"}`,
+	})
+	fake.Headers["GET /repos/o/r/labels"] = http.Header{
+		"Link": {`<https://api.github.com/repositories/1/labels?page=2>; rel="next", <https://api.github.com/repositories/1/labels?page=2>; rel="last"`},
+	}
+	fake.Headers["GET /repos/o/r"] = fake.Headers["GET /repos/o/r/labels"]
+
+	var labels []struct {
+		Name string `json:"name"`
+	}
+	if err := client.REST("GET", "repos/o/r/labels", &bytes.Buffer{}, &labels); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, l := range labels {
+		names = append(names, l.Name)
+	}
+	if got := strings.Join(names, ","); got != "bug,docs,triage" {
+		t.Errorf("labels %s, want both pages", got)
+	}
+	if got := strings.Join(fake.Requests(), ","); got != "GET /repos/o/r/labels,GET /repositories/1/labels" {
+		t.Errorf("sent %s, want the first page and the next one", got)
+	}
+
+	// only lists are paged
+	var repo struct {
+		Name string `json:"name"`
+	}
+	if err := client.REST("GET", "repos/o/r", &bytes.Buffer{}, &repo); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Requests(); len(got) != 1 {
+		t.Errorf("sent %v for a single object, want one request", got)
+	}
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mkrakowitzer/ghsettings/config"
+)
+
+// ExportRepository reads the live settings, topics, collaborators, teams,
+// labels, deploy keys, Actions variables, environments, rulesets and branch
+// protection rules of a repository into a config. Webhooks and secrets are
+// left out as their secrets can not be read.
+// Organisation admins are left out of the collaborators as they are never
+// removed by --enforce.
+func ExportRepository(client *Client, org string, reponame string) (*config.C, error) {
+
+	c := &config.C{
+		Collaborators: []config.Collaborator{},
+		Teams:         []config.Team{},
+		Branches:      []config.Branch{},
+		Labels:        []config.Label{},
+		DeployKeys:    []config.DeployKey{},
+		Variables:     []config.Variable{},
+		Environments:  []config.Environment{},
+		Rulesets:      []config.Ruleset{},
+	}
+
+	repo, err := GetRepository(client, org, reponame)
+	if err != nil {
+		return nil, err
+	}
+	c.Repository = config.Repository{
+		Name:                repo.Name,
+		Description:         config.String(repo.Description),
+		Homepage:            config.String(repo.Homepage),
+		Private:             config.Bool(repo.Private),
+		HasIssues:           config.Bool(repo.HasIssues),
+		HasProjects:         config.Bool(repo.HasProjects),
+		HasWiki:             config.Bool(repo.HasWiki),
+		HasDownloads:        config.Bool(repo.HasDownloads),
+		DefaultBranch:       config.String(repo.DefaultBranch),
+		AllowSquashMerge:    config.Bool(repo.AllowSquashMerge),
+		AllowMergeCommit:    config.Bool(repo.AllowMergeCommit),
+		AllowRebaseMerge:    config.Bool(repo.AllowRebaseMerge),
+		DeleteBranchOnMerge: config.Bool(repo.DeleteBranchOnMerge),
+		Topics:              repo.Topics,
+	}
+
+	admins, err := AdminList(client, org)
+	if err != nil {
+		return nil, err
+	}
+	collaborators, err := ListCollaborators(client, org, reponame)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range collaborators {
+		if contains(admins, k.Login) {
+			continue
+		}
+		c.Collaborators = append(c.Collaborators, config.Collaborator{
+			Username:   k.Login,
+			Permission: k.Permissions.Name(),
+		})
+	}
+
+	teams, err := ListTeams(client, org, reponame)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range teams {
+		c.Teams = append(c.Teams, config.Team{
+			Name:       k.Slug,
+			Permission: k.Permission,
+		})
+	}
+
+	labels, err := ListLabels(client, org, reponame)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range labels {
+		c.Labels = append(c.Labels, config.Label{
+			Name:        k.Name,
+			Color:       config.String(k.Color),
+			Description: config.String(k.Description),
+		})
+	}
+
+	keys, err := ListDeployKeys(client, org, reponame)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		c.DeployKeys = append(c.DeployKeys, config.DeployKey{
+			Title:    k.Title,
+			Key:      k.Key,
+			ReadOnly: config.Bool(k.ReadOnly),
+		})
+	}
+
+	variables, err := ListVariables(client, org, reponame)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range variables {
+		c.Variables = append(c.Variables, config.Variable{
+			Name:  k.Name,
+			Value: k.Value,
+		})
+	}
+
+	environments, err := ListEnvironments(client, org, reponame)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range environments {
+		users, teams := k.ReviewerNames()
+		env := config.Environment{
+			Name:               k.Name,
+			WaitTimer:          config.Int(k.WaitTimer()),
+			Reviewers:          &config.EnvironmentReviewer{Users: users, Teams: teams},
+			DeploymentBranches: config.String(k.DeploymentBranches()),
+			Variables:          []config.Variable{},
+		}
+		if k.DeploymentBranches() == "custom" {
+			policies, err := ListDeploymentBranchPolicies(client, org, reponame, k.Name)
+			if err != nil {
+				return nil, err
+			}
+			env.BranchPatterns = []string{}
+			for _, p := range policies {
+				env.BranchPatterns = append(env.BranchPatterns, p.Name)
+			}
+		}
+		variables, err := ListEnvironmentVariables(client, org, reponame, k.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range variables {
+			env.Variables = append(env.Variables, config.Variable{
+				Name:  v.Name,
+				Value: v.Value,
+			})
+		}
+		c.Environments = append(c.Environments, env)
+	}
+
+	rulesets, err := ListRulesets(client, org, reponame)
+	if err != nil {
+		return nil, err
+	}
+	var orgTeams []OrgTeam
+	for _, k := range rulesets {
+		r, err := GetRuleset(client, org, reponame, fmt.Sprint(k.ID))
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range r.BypassActors {
+			if a.ActorType == "Team" && orgTeams == nil {
+				if orgTeams, err = ListOrgTeams(client, org); err != nil {
+					return nil, err
+				}
+			}
+		}
+		c.Rulesets = append(c.Rulesets, exportRuleset(r, orgTeams))
+	}
+
+	rules, err := GetBranchProtectionRules(client, org, reponame)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range rules.Organization.Repository.BranchProtectionRules.Nodes {
+		// GitHub keeps requiresStrictStatusChecks when the status checks
+		// are turned off or cleared, which a config may not do
+		var strict *bool
+		if k.RequiresStatusChecks && len(k.RequiredStatusCheckContexts) > 0 {
+			strict = config.Bool(k.RequiresStrictStatusChecks)
+		}
+		c.Branches = append(c.Branches, config.Branch{
+			Name:                         k.Pattern,
+			RequiredApprovingReviewCount: config.Int(k.RequiredApprovingReviewCount),
+			RequiresStatusChecks:         config.Bool(k.RequiresStatusChecks),
+			RequiredStatusCheckContexts:  append([]string{}, k.RequiredStatusCheckContexts...),
+			RequiresApprovingReviews:     config.Bool(k.RequiresApprovingReviews),
+			RequiresCodeOwnerReviews:     config.Bool(k.RequiresCodeOwnerReviews),
+			RequiresCommitSignatures:     config.Bool(k.RequiresCommitSignatures),
+			RequiresStrictStatusChecks:   strict,
+			RestrictsPushes:              config.Bool(k.RestrictsPushes),
+			IsAdminEnforced:              config.Bool(k.IsAdminEnforced),
+			DismissesStaleReviews:        config.Bool(k.DismissesStaleReviews),
+			PushActorIds:                 k.PushActorIds(),
+		})
+	}
+
+	return c, nil
+}
+
+// exportRuleset converts a ruleset into its config. Ref patterns lose their
+// refs/heads/ or refs/tags/ prefix and rules ghsettings does not model are
+// left out. When a bypass actor can not be written in a config, such as a
+// custom repository role, the bypass actors are left out altogether so that
+// applying the config keeps them.
+func exportRuleset(r *Ruleset, teams []OrgTeam) config.Ruleset {
+	prefix := "refs/heads/"
+	if r.Target == "tag" {
+		prefix = "refs/tags/"
+	}
+	trim := func(patterns []string) []string {
+		refs := []string{}
+		for _, p := range patterns {
+			refs = append(refs, strings.TrimPrefix(p, prefix))
+		}
+		return refs
+	}
+	rs := config.Ruleset{
+		Name:         r.Name,
+		Target:       config.String(r.Target),
+		Enforcement:  config.String(r.Enforcement),
+		Include:      trim(r.Conditions.RefName.Include),
+		Exclude:      trim(r.Conditions.RefName.Exclude),
+		BypassActors: []config.BypassActor{},
+		Rules:        &config.Rules{},
+	}
+
+	for _, a := range r.BypassActors {
+		id := int64(0)
+		if a.ActorID != nil {
+			id = *a.ActorID
+		}
+		actor := config.BypassActor{}
+		switch a.BypassMode {
+		case "always":
+		case "pull_request":
+			actor.Mode = config.String(a.BypassMode)
+		default:
+			rs.BypassActors = nil
+		}
+		switch a.ActorType {
+		case "Team":
+			for _, t := range teams {
+				if int64(t.ID) == id {
+					actor.Team = t.Slug
+				}
+			}
+			if actor.Team == "" {
+				rs.BypassActors = nil
+			}
+		case "RepositoryRole":
+			for role, k := range repositoryRoles {
+				if k == id {
+					actor.Role = config.String(role)
+				}
+			}
+			if actor.Role == nil {
+				rs.BypassActors = nil
+			}
+		case "OrganizationAdmin":
+			actor.Role = config.String("org_admin")
+		case "Integration":
+			actor.AppID = config.Int(int(id))
+		case "DeployKey":
+			actor.DeployKeys = config.Bool(true)
+		default:
+			rs.BypassActors = nil
+		}
+		if rs.BypassActors == nil {
+			break
+		}
+		rs.BypassActors = append(rs.BypassActors, actor)
+	}
+
+	for _, k := range r.Rules {
+		p := k.Parameters
+		switch k.Type {
+		case "pull_request":
+			rs.Rules.PullRequest = &config.PullRequestRule{
+				RequiredApprovingReviewCount:   intParam(p, "required_approving_review_count"),
+				DismissStaleReviewsOnPush:      boolParam(p, "dismiss_stale_reviews_on_push"),
+				RequireCodeOwnerReview:         boolParam(p, "require_code_owner_review"),
+				RequireLastPushApproval:        boolParam(p, "require_last_push_approval"),
+				RequiredReviewThreadResolution: boolParam(p, "required_review_thread_resolution"),
+			}
+		case "required_status_checks":
+			checks := &config.StatusChecksRule{
+				Strict:   boolParam(p, "strict_required_status_checks_policy"),
+				Contexts: []string{},
+			}
+			list, _ := p["required_status_checks"].([]interface{})
+			for _, c := range list {
+				if m, ok := c.(map[string]interface{}); ok {
+					checks.Contexts = append(checks.Contexts, fmt.Sprint(m["context"]))
+				}
+			}
+			rs.Rules.RequiredStatusChecks = checks
+		case "required_signatures":
+			rs.Rules.RequiredSignatures = config.Bool(true)
+		case "non_fast_forward":
+			rs.Rules.NonFastForward = config.Bool(true)
+		case "deletion":
+			rs.Rules.Deletion = config.Bool(true)
+		case "required_linear_history":
+			rs.Rules.RequiredLinearHistory = config.Bool(true)
+		}
+	}
+	return rs
+}
+
+// boolParam returns a boolean rule parameter, or nil when it is missing
+func boolParam(params map[string]interface{}, name string) *bool {
+	if v, ok := params[name].(bool); ok {
+		return config.Bool(v)
+	}
+	return nil
+}
+
+// intParam returns a numeric rule parameter, or nil when it is missing
+func intParam(params map[string]interface{}, name string) *int {
+	if v, ok := params[name].(float64); ok {
+		return config.Int(int(v))
+	}
+	return nil
+}
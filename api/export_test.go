@@ -0,0 +1,108 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkrakowitzer/ghsettings/config"
+	"gopkg.in/yaml.v2"
+)
+
+// exportResponses is a repository using every resource ExportRepository
+// reads. Its branch protection rules include one GitHub left strict after
+// its status checks were turned off, and its rulesets are listed on two
+// pages. The tags ruleset can be bypassed by a custom role, which a config
+// can not name.
+var exportResponses = map[string]string{
+	"GET /repos/o/r": `{"node_id":"R1","name":"r","description":"Service","private":true,"has_issues":true,
+		"default_branch":"main","allow_squash_merge":true,"delete_branch_on_merge":true,"topics":["go"]}`,
+	"GET /orgs/o/members": `[{"login":"owner"}]`,
+	"GET /repos/o/r/collaborators": `[{"login":"owner","permissions":{"admin":true}},
+		{"login":"dev","permissions":{"push":true,"pull":true}}]`,
+	"GET /repos/o/r/teams":  `[{"name":"Platform","slug":"platform","permission":"push"}]`,
+	"GET /repos/o/r/labels": `[{"name":"bug","color":"d73a4a","description":"Something is broken"}]`,
+	"GET /repos/o/r/keys": `[{"id":1,"title":"ci","read_only":true,
+		"key":"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"}]`,
+	"GET /repos/o/r/actions/variables": `{"total_count":1,"variables":[{"name":"REGION","value":"eu"}]}`,
+	"GET /repos/o/r/environments": `{"total_count":1,"environments":[{"id":1,"name":"production",
+		"protection_rules":[{"type":"wait_timer","wait_timer":5},
+			{"type":"required_reviewers","reviewers":[{"type":"Team","reviewer":{"id":21,"slug":"platform","name":"Platform"}}]}],
+		"deployment_branch_policy":{"protected_branches":false,"custom_branch_policies":true}}]}`,
+	"GET /repos/o/r/environments/production/deployment-branch-policies":       `{"total_count":1,"branch_policies":[{"id":7,"name":"main"}]}`,
+	"GET /repos/o/r/environments/production/variables":                        `{"total_count":0,"variables":[]}`,
+	"GET /repos/o/r/rulesets?includes_parents=false&per_page=100":             `[{"id":10,"name":"main","target":"branch","enforcement":"active"}]`,
+	"GET /repositories/1/rulesets?includes_parents=false&per_page=100&page=2": `[{"id":11,"name":"tags","target":"tag","enforcement":"evaluate"}]`,
+	"GET /repos/o/r/rulesets/10": `{"id":10,"name":"main","target":"branch","enforcement":"active",
+		"bypass_actors":[{"actor_id":5,"actor_type":"RepositoryRole","bypass_mode":"always"},
+			{"actor_id":21,"actor_type":"Team","bypass_mode":"pull_request"},
+			{"actor_id":null,"actor_type":"DeployKey","bypass_mode":"always"}],
+		"conditions":{"ref_name":{"include":["~DEFAULT_BRANCH","refs/heads/release/*"],"exclude":[]}},
+		"rules":[{"type":"deletion"},{"type":"creation"},
+			{"type":"pull_request","parameters":{"required_approving_review_count":2,"dismiss_stale_reviews_on_push":true,
+				"require_code_owner_review":false,"require_last_push_approval":false,"required_review_thread_resolution":false}},
+			{"type":"required_status_checks","parameters":{"strict_required_status_checks_policy":true,
+				"required_status_checks":[{"context":"ci","integration_id":3}]}}]}`,
+	"GET /repos/o/r/rulesets/11": `{"id":11,"name":"tags","target":"tag","enforcement":"evaluate",
+		"bypass_actors":[{"actor_id":1,"actor_type":"OrganizationAdmin","bypass_mode":"always"},
+			{"actor_id":99,"actor_type":"RepositoryRole","bypass_mode":"always"}],
+		"conditions":{"ref_name":{"include":["refs/tags/v*"],"exclude":[]}},
+		"rules":[{"type":"deletion"},{"type":"non_fast_forward"}]}`,
+	"GET /orgs/o/teams": `[{"id":21,"slug":"platform","name":"Platform"}]`,
+	"POST /graphql": `{"data":{"organization":{"repository":{"branchProtectionRules":{"nodes":[
+		{"id":"B1","pattern":"main","requiresApprovingReviews":true,"requiredApprovingReviewCount":1,
+			"requiresStatusChecks":true,"requiresStrictStatusChecks":true,"requiredStatusCheckContexts":["ci"]},
+		{"id":"B2","pattern":"release/*","requiresStatusChecks":false,"requiresStrictStatusChecks":true,
+			"requiredStatusCheckContexts":[]}]}}}}}`,
+}
+
+// TestExportRoundTrip checks that an exported config passes validation and
+// plans no changes against the repository it was exported from
+func TestExportRoundTrip(t *testing.T) {
+	client, fake := newFakeClient(exportResponses)
+	fake.Headers["GET /repos/o/r/rulesets?includes_parents=false&per_page=100"] = http.Header{
+		"Link": {`<https://api.github.com/repositories/1/rulesets?includes_parents=false&per_page=100&page=2>; rel="next"`},
+	}
+
+	c, err := ExportRepository(client, "o", "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Rulesets) != 2 {
+		t.Fatalf("exported %d rulesets, want both pages", len(c.Rulesets))
+	}
+	if got := len(c.Rulesets[0].BypassActors); got != 3 {
+		t.Errorf("main ruleset has %d bypass actors, want 3", got)
+	}
+	if c.Rulesets[1].BypassActors != nil {
+		t.Errorf("tags ruleset exports bypass actors %+v, want them left out", c.Rulesets[1].BypassActors)
+	}
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "r.yaml")
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	read, err := config.Read(file, nil)
+	if err != nil {
+		t.Fatalf("exported config is invalid: %s\n%s", err, data)
+	}
+
+	plan, err := PlanRepository(client, "o", read, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range plan.Changes {
+		t.Errorf("unexpected change %s %s %q %+v", c.Action, c.Resource, c.Name, c.Fields)
+	}
+}
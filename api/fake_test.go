@@ -0,0 +1,10 @@
+package api
+
+import "github.com/mkrakowitzer/ghsettings/api/apitest"
+
+// newFakeClient returns a client that sends its requests to a FakeGitHub
+// answering with responses
+func newFakeClient(responses map[string]string) (*Client, *apitest.FakeGitHub) {
+	f := apitest.NewFakeGitHub(responses)
+	return NewClient(ReplaceTripper(f)), f
+}
@@ -0,0 +1,1419 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mkrakowitzer/ghsettings/config"
+	"github.com/shurcooL/githubv4"
+)
+
+// Action describes what a Change does to a resource
+type Action string
+
+const (
+	ActionAdd    Action = "+"
+	ActionChange Action = "~"
+	ActionRemove Action = "-"
+)
+
+// FieldDiff is a single setting whose live value differs from the desired value
+type FieldDiff struct {
+	Name string
+	Old  interface{}
+	New  interface{}
+}
+
+// Change is the addition, modification or removal of a single resource
+type Change struct {
+	Action   Action
+	Resource string
+	Name     string
+	Fields   []FieldDiff
+
+	// ID identifies the live resource when it differs from Name, e.g. the
+	// node ID of a branch protection rule or the slug of a team.
+	ID githubv4.ID
+
+	// UpdatedAt is when GitHub last changed a live secret, or the webhook
+	// holding one, whose value can not be read back to compare
+	UpdatedAt time.Time
+
+	// Parent names the environment an environment secret, variable or
+	// branch pattern belongs to
+	Parent string
+}
+
+// Plan holds every change required to bring a repository in line with its config
+type Plan struct {
+	Org          string
+	Repository   string
+	RepositoryID string
+	Changes      []Change
+}
+
+// Count returns the number of changes with the given action
+func (p *Plan) Count(action Action) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// PlanRepository compares the live state of a repository with its config
+// without issuing any mutating request. Resources that are not in the config
+// are only planned for removal when enforce is set.
+func PlanRepository(client *Client, org string, config config.C, enforce bool) (*Plan, error) {
+
+	repo, err := GetRepository(client, org, config.Repository.Name)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Org: org, Repository: config.Repository.Name, RepositoryID: repo.NodeID}
+	planRepository(plan, config, repo)
+	planTopics(plan, config, repo.Topics, enforce)
+
+	collaborators, err := ListCollaborators(client, org, config.Repository.Name)
+	if err != nil {
+		return nil, err
+	}
+	var admins []string
+	if enforce {
+		admins, err = AdminList(client, org)
+		if err != nil {
+			return nil, err
+		}
+	}
+	planCollaborators(plan, config, collaborators, admins, enforce)
+
+	teams, err := ListTeams(client, org, config.Repository.Name)
+	if err != nil {
+		return nil, err
+	}
+	planTeams(plan, config, teams, enforce)
+
+	rules, err := GetBranchProtectionRules(client, org, config.Repository.Name)
+	if err != nil {
+		return nil, err
+	}
+	planBranchProtections(plan, config, rules, enforce)
+
+	if config.Labels != nil {
+		labels, err := ListLabels(client, org, config.Repository.Name)
+		if err != nil {
+			return nil, err
+		}
+		planLabels(plan, config, labels, enforce)
+	}
+
+	if config.DeployKeys != nil {
+		keys, err := ListDeployKeys(client, org, config.Repository.Name)
+		if err != nil {
+			return nil, err
+		}
+		planDeployKeys(plan, config, keys, enforce)
+	}
+
+	if config.Secrets != nil {
+		secrets, err := ListSecrets(client, org, config.Repository.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := planSecrets(plan, config, secrets, enforce); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Variables != nil {
+		variables, err := ListVariables(client, org, config.Repository.Name)
+		if err != nil {
+			return nil, err
+		}
+		planVariables(plan, config, variables, enforce)
+	}
+
+	if config.Environments != nil {
+		environments, err := ListEnvironments(client, org, config.Repository.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := planEnvironments(client, plan, config, environments, enforce); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Rulesets != nil {
+		rulesets, err := ListRulesets(client, org, config.Repository.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := planRulesets(client, plan, config, rulesets, enforce); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Webhooks != nil {
+		hooks, err := ListWebhooks(client, org, config.Repository.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := planWebhooks(plan, config, hooks, enforce); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// PlanOrganization compares the live settings of the organisation with the
+// org config. Its plan has no Repository.
+func PlanOrganization(client *Client, org string, config config.Org, enforce bool) (*Plan, error) {
+
+	plan := &Plan{Org: org}
+
+	if config.Variables != nil {
+		variables, err := ListOrgVariables(client, org)
+		if err != nil {
+			return nil, err
+		}
+		selected := map[string][]string{}
+		for _, v := range variables {
+			if v.Visibility != "selected" {
+				continue
+			}
+			selected[strings.ToUpper(v.Name)], err = ListOrgVariableRepositories(client, org, v.Name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		planOrgVariables(plan, config, variables, selected, enforce)
+	}
+
+	return plan, nil
+}
+
+// ChangeError records a change that could not be applied
+type ChangeError struct {
+	Change Change
+	Err    error
+}
+
+func (e ChangeError) Error() string {
+	return fmt.Sprintf("%s '%s': %s", e.Change.Resource, e.Change.Name, e.Err)
+}
+
+// ApplyErrors holds every change of a plan that could not be applied
+type ApplyErrors []ChangeError
+
+func (e ApplyErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, k := range e {
+		messages = append(messages, k.Error())
+	}
+	return strings.Join(messages, ", ")
+}
+
+// ApplyPlan issues one mutating request per change in the plan. A plan with
+// no changes makes no requests at all. A failed change does not stop the
+// remaining ones; the failures are returned as ApplyErrors. The public key
+// secrets are sealed with is read once for the repository and for each
+// environment.
+func ApplyPlan(client *Client, plan *Plan) error {
+	var errs ApplyErrors
+	keys := publicKeys{}
+	for _, c := range plan.Changes {
+		if err := applyChange(client, plan, c, keys); err != nil {
+			errs = append(errs, ChangeError{Change: c, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// publicKeys holds the secret public keys read while applying a plan, by
+// environment. The key of the repository is held under "".
+type publicKeys map[string]*SecretPublicKey
+
+func applyChange(client *Client, plan *Plan, c Change, keys publicKeys) error {
+	switch c.Resource {
+	case "repository":
+		return UpdateRepository(client, plan.Org, plan.RepositoryID, plan.Repository, c.Fields)
+	case "topics":
+		return ReplaceTopics(client, plan.Org, plan.Repository, c.Fields[0].New.([]string))
+	case "collaborator":
+		if c.Action == ActionRemove {
+			return CollaboratorRemoveFromRepo(client, plan.Org, plan.Repository, c.Name)
+		}
+		return CollaboratorAddToRepo(client, plan.Org, plan.Repository, c.Name, fmt.Sprint(c.Fields[0].New))
+	case "team":
+		switch c.Action {
+		case ActionAdd:
+			teams, err := ListOrgTeams(client, plan.Org)
+			if err != nil {
+				return err
+			}
+			t := MatchTeam(teams, c.Name)
+			if t == nil {
+				return fmt.Errorf("team %s not found in %s", c.Name, plan.Org)
+			}
+			return TeamAddToRepo(client, plan.Org, plan.Repository, t.Slug, fmt.Sprint(c.Fields[0].New))
+		case ActionRemove:
+			return TeamDeleteFromRepo(client, plan.Org, plan.Repository, fmt.Sprint(c.ID))
+		}
+		return TeamAddToRepo(client, plan.Org, plan.Repository, fmt.Sprint(c.ID), fmt.Sprint(c.Fields[0].New))
+	case "label":
+		fields := map[string]interface{}{}
+		for _, f := range c.Fields {
+			fields[f.Name] = f.New
+		}
+		switch c.Action {
+		case ActionAdd:
+			return LabelAddToRepo(client, plan.Org, plan.Repository, c.Name, fields)
+		case ActionRemove:
+			return LabelDeleteFromRepo(client, plan.Org, plan.Repository, c.Name)
+		}
+		return LabelUpdate(client, plan.Org, plan.Repository, fmt.Sprint(c.ID), fields)
+	case "webhook":
+		switch c.Action {
+		case ActionAdd:
+			return WebhookAddToRepo(client, plan.Org, plan.Repository, c.Name, c.Fields)
+		case ActionRemove:
+			return WebhookDeleteFromRepo(client, plan.Org, plan.Repository, fmt.Sprint(c.ID))
+		}
+		return WebhookUpdate(client, plan.Org, plan.Repository, fmt.Sprint(c.ID), c.Fields)
+	case "deploy_key":
+		if c.Action == ActionRemove {
+			return DeployKeyDeleteFromRepo(client, plan.Org, plan.Repository, fmt.Sprint(c.ID))
+		}
+		return DeployKeyAddToRepo(client, plan.Org, plan.Repository, c.Name, c.Fields)
+	case "secret":
+		if c.Action == ActionRemove {
+			return SecretDeleteFromRepo(client, plan.Org, plan.Repository, c.Name)
+		}
+		key := keys[""]
+		if key == nil {
+			var err error
+			if key, err = GetSecretPublicKey(client, plan.Org, plan.Repository); err != nil {
+				return err
+			}
+			keys[""] = key
+		}
+		return SecretUpdate(client, plan.Org, plan.Repository, c.Name, c.Fields[0].New.(Secret), key)
+	case "variable":
+		switch c.Action {
+		case ActionAdd:
+			return VariableAddToRepo(client, plan.Org, plan.Repository, c.Name, fmt.Sprint(c.Fields[0].New))
+		case ActionRemove:
+			return VariableDeleteFromRepo(client, plan.Org, plan.Repository, c.Name)
+		}
+		return VariableUpdate(client, plan.Org, plan.Repository, c.Name, fmt.Sprint(c.Fields[0].New))
+	case "environment":
+		switch c.Action {
+		case ActionAdd:
+			return EnvironmentAddToRepo(client, plan.Org, plan.Repository, c.Name, c.Fields)
+		case ActionRemove:
+			return EnvironmentDeleteFromRepo(client, plan.Org, plan.Repository, c.Name)
+		}
+		return EnvironmentUpdate(client, plan.Org, plan.Repository, c.Name, c.Fields)
+	case "environment_branch_pattern":
+		if c.Action == ActionRemove {
+			return DeploymentBranchPolicyDelete(client, plan.Org, plan.Repository, c.Parent, fmt.Sprint(c.ID))
+		}
+		return DeploymentBranchPolicyAdd(client, plan.Org, plan.Repository, c.Parent, c.Name)
+	case "environment_secret":
+		if c.Action == ActionRemove {
+			return EnvironmentSecretDelete(client, plan.Org, plan.Repository, c.Parent, c.Name)
+		}
+		key := keys[c.Parent]
+		if key == nil {
+			var err error
+			if key, err = GetEnvironmentSecretPublicKey(client, plan.Org, plan.Repository, c.Parent); err != nil {
+				return err
+			}
+			keys[c.Parent] = key
+		}
+		return EnvironmentSecretUpdate(client, plan.Org, plan.Repository, c.Parent, c.Name, c.Fields[0].New.(Secret), key)
+	case "environment_variable":
+		switch c.Action {
+		case ActionAdd:
+			return EnvironmentVariableAdd(client, plan.Org, plan.Repository, c.Parent, c.Name, fmt.Sprint(c.Fields[0].New))
+		case ActionRemove:
+			return EnvironmentVariableDelete(client, plan.Org, plan.Repository, c.Parent, c.Name)
+		}
+		return EnvironmentVariableUpdate(client, plan.Org, plan.Repository, c.Parent, c.Name, fmt.Sprint(c.Fields[0].New))
+	case "ruleset":
+		switch c.Action {
+		case ActionAdd:
+			return RulesetAddToRepo(client, plan.Org, plan.Repository, c.Name, c.Fields)
+		case ActionRemove:
+			return RulesetDeleteFromRepo(client, plan.Org, plan.Repository, fmt.Sprint(c.ID))
+		}
+		return RulesetUpdate(client, plan.Org, plan.Repository, fmt.Sprint(c.ID), c.Fields)
+	case "org_variable":
+		fields := map[string]interface{}{}
+		for _, f := range c.Fields {
+			fields[f.Name] = f.New
+		}
+		switch c.Action {
+		case ActionAdd:
+			return OrgVariableAdd(client, plan.Org, c.Name, fields)
+		case ActionRemove:
+			return OrgVariableDelete(client, plan.Org, c.Name)
+		}
+		return OrgVariableUpdate(client, plan.Org, c.Name, fields)
+	case "branch_protection":
+		if c.Action == ActionRemove {
+			return DeleteBranchProtections(client, c.ID)
+		}
+		input := map[string]interface{}{}
+		for _, f := range c.Fields {
+			input[f.Name] = f.New
+		}
+		if c.Action == ActionAdd {
+			input["repositoryId"] = plan.RepositoryID
+			input["pattern"] = c.Name
+			return CreateBranchProtections(client, input)
+		}
+		input["branchProtectionRuleId"] = c.ID
+		return UpdateBranchProtections(client, input)
+	}
+	return fmt.Errorf("unknown resource '%s'", c.Resource)
+}
+
+func planRepository(plan *Plan, config config.C, repo *RepositoryInfo) {
+	r := config.Repository
+	fields := diffFields(specified([]FieldDiff{
+		{"description", repo.Description, r.Description},
+		{"homepage", repo.Homepage, r.Homepage},
+		{"private", repo.Private, r.Private},
+		{"has_issues", repo.HasIssues, r.HasIssues},
+		{"has_projects", repo.HasProjects, r.HasProjects},
+		{"has_wiki", repo.HasWiki, r.HasWiki},
+		{"has_downloads", repo.HasDownloads, r.HasDownloads},
+		{"default_branch", repo.DefaultBranch, r.DefaultBranch},
+		{"allow_squash_merge", repo.AllowSquashMerge, r.AllowSquashMerge},
+		{"allow_merge_commit", repo.AllowMergeCommit, r.AllowMergeCommit},
+		{"allow_rebase_merge", repo.AllowRebaseMerge, r.AllowRebaseMerge},
+		{"delete_branch_on_merge", repo.DeleteBranchOnMerge, r.DeleteBranchOnMerge},
+	}))
+	if len(fields) > 0 {
+		plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: "repository", Name: r.Name, Fields: fields})
+	}
+}
+
+// planTopics adds the topics in the config to the live ones. With enforce
+// the live topics are replaced, removing those not in the config. GitHub
+// only takes the complete list, so a single change holds all topics.
+func planTopics(plan *Plan, config config.C, live []string, enforce bool) {
+	if config.Repository.Topics == nil {
+		return
+	}
+	topics := append([]string{}, config.Repository.Topics...)
+	if !enforce {
+		for _, t := range live {
+			if !contains(topics, t) {
+				topics = append(topics, t)
+			}
+		}
+	}
+	if live == nil {
+		live = []string{}
+	}
+	if fields := diffFields([]FieldDiff{{"names", live, topics}}); len(fields) > 0 {
+		plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: "topics", Name: config.Repository.Name, Fields: fields})
+	}
+}
+
+// planCollaborators matches collaborators by login, ignoring case like
+// GitHub does. The organisation owners in admins are never removed.
+func planCollaborators(plan *Plan, config config.C, live Collaborators, admins []string, enforce bool) {
+	current := make(map[string]string, len(live))
+	for _, k := range live {
+		current[strings.ToLower(k.Login)] = k.Permissions.Name()
+	}
+	isAdmin := make(map[string]bool, len(admins))
+	for _, a := range admins {
+		isAdmin[strings.ToLower(a)] = true
+	}
+
+	wanted := make(map[string]bool, len(config.Collaborators))
+	for _, s := range config.Collaborators {
+		wanted[strings.ToLower(s.Username)] = true
+		old, ok := current[strings.ToLower(s.Username)]
+		if !ok {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Resource: "collaborator", Name: s.Username, Fields: []FieldDiff{
+				{"permission", nil, s.Permission},
+			}})
+			continue
+		}
+		if fields := diffFields([]FieldDiff{{"permission", old, s.Permission}}); len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: "collaborator", Name: s.Username, Fields: fields})
+		}
+	}
+
+	if !enforce {
+		return
+	}
+	for _, k := range live {
+		if wanted[strings.ToLower(k.Login)] || isAdmin[strings.ToLower(k.Login)] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "collaborator", Name: k.Login, Fields: []FieldDiff{
+			{"permission", k.Permissions.Name(), nil},
+		}})
+	}
+}
+
+// planTeams matches teams by name or slug, ignoring case, like MatchTeam does.
+func planTeams(plan *Plan, config config.C, live Teams, enforce bool) {
+	wanted := make(map[string]bool, len(config.Teams))
+	for _, s := range config.Teams {
+		found := false
+		for _, k := range live {
+			if !strings.EqualFold(k.Name, s.Name) && !strings.EqualFold(k.Slug, s.Name) {
+				continue
+			}
+			found = true
+			wanted[k.Slug] = true
+			if fields := diffFields([]FieldDiff{{"permission", k.Permission, s.Permission}}); len(fields) > 0 {
+				plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: "team", Name: s.Name, Fields: fields, ID: k.Slug})
+			}
+		}
+		if !found {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Resource: "team", Name: s.Name, Fields: []FieldDiff{
+				{"permission", nil, s.Permission},
+			}})
+		}
+	}
+
+	if !enforce {
+		return
+	}
+	for _, k := range live {
+		if wanted[k.Slug] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "team", Name: k.Name, ID: k.Slug, Fields: []FieldDiff{
+			{"permission", k.Permission, nil},
+		}})
+	}
+}
+
+func planBranchProtections(plan *Plan, config config.C, rules *BranchProtectionRules, enforce bool) {
+	nodes := rules.Organization.Repository.BranchProtectionRules.Nodes
+
+	wanted := make(map[string]bool, len(config.Branches))
+	for _, s := range config.Branches {
+		wanted[s.Name] = true
+		found := false
+		for _, k := range nodes {
+			if k.Pattern != s.Name {
+				continue
+			}
+			found = true
+			if fields := diffFields(branchFields(&k, s)); len(fields) > 0 {
+				plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: "branch_protection", Name: s.Name, Fields: fields, ID: k.ID})
+			}
+		}
+		if !found {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Resource: "branch_protection", Name: s.Name, Fields: branchFields(nil, s)})
+		}
+	}
+
+	if !enforce {
+		return
+	}
+	for _, k := range nodes {
+		if wanted[k.Pattern] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "branch_protection", Name: k.Pattern, ID: k.ID})
+	}
+}
+
+// planLabels matches labels by name, ignoring case, or by oldname to rename
+// them. Labels are only planned when the config has a labels list, so
+// --enforce does not remove the labels of repositories that do not manage
+// them. Field names are those of the REST API. Colors are compared without
+// the leading # and ignoring case.
+func planLabels(plan *Plan, config config.C, live Labels, enforce bool) {
+	find := func(name string) *Label {
+		for i := range live {
+			if strings.EqualFold(live[i].Name, name) {
+				return &live[i]
+			}
+		}
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(config.Labels))
+	for _, s := range config.Labels {
+		color := s.Color
+		if color != nil {
+			c := strings.ToLower(strings.TrimPrefix(*color, "#"))
+			color = &c
+		}
+
+		k := find(s.Name)
+		if k == nil && s.OldName != "" {
+			k = find(s.OldName)
+		}
+		if k == nil {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Resource: "label", Name: s.Name, Fields: specified([]FieldDiff{
+				{"color", nil, color},
+				{"description", nil, s.Description},
+			})})
+			continue
+		}
+
+		wanted[strings.ToLower(k.Name)] = true
+		fields := diffFields(specified([]FieldDiff{
+			{"new_name", k.Name, &s.Name},
+			{"color", strings.ToLower(k.Color), color},
+			{"description", k.Description, s.Description},
+		}))
+		if len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: "label", Name: s.Name, Fields: fields, ID: k.Name})
+		}
+	}
+
+	if !enforce {
+		return
+	}
+	for _, k := range live {
+		if wanted[strings.ToLower(k.Name)] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "label", Name: k.Name, Fields: []FieldDiff{
+			{"color", k.Color, nil},
+		}})
+	}
+}
+
+// planWebhooks matches webhooks by URL. GitHub never returns a secret, so
+// like an Actions secret it is planned to be written on every run; callers
+// that remember what they wrote can drop an unchanged secret using
+// UpdatedAt. It is kept in the plan as a Secret so it is never printed. Like
+// labels, webhooks are only planned when the config has a webhooks list.
+func planWebhooks(plan *Plan, config config.C, live Webhooks, enforce bool) error {
+	wanted := make(map[int64]bool, len(config.Webhooks))
+	for _, s := range config.Webhooks {
+		var secret interface{}
+		if s.SecretEnv != "" {
+			v := os.Getenv(s.SecretEnv)
+			if v == "" {
+				return fmt.Errorf("webhook %s: environment variable %s holding the secret is not set", s.URL, s.SecretEnv)
+			}
+			secret = Secret(v)
+		}
+
+		var k *Webhook
+		for i := range live {
+			if live[i].Config.URL == s.URL {
+				k = &live[i]
+				break
+			}
+		}
+		if k == nil {
+			events := s.Events
+			if events == nil {
+				events = []string{"push"}
+			}
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Resource: "webhook", Name: s.URL, Fields: specified([]FieldDiff{
+				{"content_type", nil, s.ContentType},
+				{"events", nil, events},
+				{"active", nil, s.Active},
+				{"insecure_ssl", nil, s.InsecureSSL},
+				{"secret", nil, secret},
+			})})
+			continue
+		}
+
+		wanted[k.ID] = true
+		fields := diffFields(specified([]FieldDiff{
+			{"content_type", k.Config.ContentType, s.ContentType},
+			{"events", k.Events, s.Events},
+			{"active", k.Active, s.Active},
+			{"insecure_ssl", k.Config.InsecureSSL == "1", s.InsecureSSL},
+		}))
+		if secret != nil {
+			var old interface{}
+			if k.Config.Secret != "" {
+				old = Secret("")
+			}
+			fields = append(fields, FieldDiff{"secret", old, secret})
+		}
+		if len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: "webhook", Name: s.URL, Fields: fields, ID: k.ID, UpdatedAt: k.UpdatedAt})
+		}
+	}
+
+	if !enforce {
+		return nil
+	}
+	for _, k := range live {
+		if wanted[k.ID] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "webhook", Name: k.Config.URL, ID: k.ID, Fields: []FieldDiff{
+			{"events", k.Events, nil},
+		}})
+	}
+	return nil
+}
+
+// planDeployKeys matches deploy keys by fingerprint. GitHub can not change a
+// deploy key, so a key whose title or read_only differs is removed and added
+// again. Keys are kept in the plan as PublicKey so only their fingerprint is
+// printed.
+func planDeployKeys(plan *Plan, config config.C, live DeployKeys, enforce bool) {
+	wanted := make(map[int64]bool, len(config.DeployKeys))
+	for _, s := range config.DeployKeys {
+		key := PublicKey(s.Key)
+		add := Change{Action: ActionAdd, Resource: "deploy_key", Name: s.Title, Fields: specified([]FieldDiff{
+			{"key", nil, key},
+			{"read_only", nil, s.ReadOnly},
+		})}
+
+		var k *DeployKey
+		for i := range live {
+			if PublicKey(live[i].Key).String() == key.String() {
+				k = &live[i]
+				break
+			}
+		}
+		if k == nil {
+			plan.Changes = append(plan.Changes, add)
+			continue
+		}
+
+		wanted[k.ID] = true
+		fields := diffFields(specified([]FieldDiff{
+			{"title", k.Title, &s.Title},
+			{"read_only", k.ReadOnly, s.ReadOnly},
+		}))
+		if len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "deploy_key", Name: k.Title, ID: k.ID, Fields: []FieldDiff{
+				{"key", PublicKey(k.Key), nil},
+				{"read_only", k.ReadOnly, nil},
+			}}, add)
+		}
+	}
+
+	if !enforce {
+		return
+	}
+	for _, k := range live {
+		if wanted[k.ID] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "deploy_key", Name: k.Title, ID: k.ID, Fields: []FieldDiff{
+			{"key", PublicKey(k.Key), nil},
+			{"read_only", k.ReadOnly, nil},
+		}})
+	}
+}
+
+// planSecrets reads the value of every secret in the config. As values can
+// not be read back, every listed secret is planned to be written; callers
+// that remember what they wrote can drop unchanged secrets using UpdatedAt.
+// Secrets are only planned when the config has a secrets list.
+func planSecrets(plan *Plan, config config.C, live []SecretInfo, enforce bool) error {
+	return planSecretList(plan, "secret", "", config.Repository.Name, config.Secrets, live, enforce)
+}
+
+// planSecretList plans the secrets of the repository, or of the environment
+// parent
+func planSecretList(plan *Plan, resource string, parent string, repository string, secrets []config.Secret, live []SecretInfo, enforce bool) error {
+	wanted := make(map[string]bool, len(secrets))
+	for _, s := range secrets {
+		value, err := s.Value(repository)
+		if err != nil {
+			return err
+		}
+		wanted[strings.ToUpper(s.Name)] = true
+
+		change := Change{Action: ActionAdd, Resource: resource, Name: s.Name, Parent: parent, Fields: []FieldDiff{
+			{"value", nil, Secret(value)},
+		}}
+		for _, k := range live {
+			if strings.EqualFold(k.Name, s.Name) {
+				change.Action = ActionChange
+				change.Fields[0].Old = Secret("")
+				change.UpdatedAt = k.UpdatedAt
+			}
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	if !enforce {
+		return nil
+	}
+	for _, k := range live {
+		if wanted[strings.ToUpper(k.Name)] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: resource, Name: k.Name, Parent: parent, Fields: []FieldDiff{
+			{"value", Secret(""), nil},
+		}})
+	}
+	return nil
+}
+
+// planVariables matches Actions variables by name, ignoring case. Their
+// values are not secret and are shown in the plan.
+func planVariables(plan *Plan, config config.C, live []Variable, enforce bool) {
+	planVariableList(plan, "variable", "", config.Variables, live, enforce)
+}
+
+// planVariableList plans the variables of the repository, or of the
+// environment parent
+func planVariableList(plan *Plan, resource string, parent string, variables []config.Variable, live []Variable, enforce bool) {
+	wanted := make(map[string]bool, len(variables))
+	for _, s := range variables {
+		wanted[strings.ToUpper(s.Name)] = true
+
+		var k *Variable
+		for i := range live {
+			if strings.EqualFold(live[i].Name, s.Name) {
+				k = &live[i]
+			}
+		}
+		if k == nil {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Resource: resource, Name: s.Name, Parent: parent, Fields: []FieldDiff{
+				{"value", nil, s.Value},
+			}})
+			continue
+		}
+		if k.Value != s.Value {
+			plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: resource, Name: k.Name, Parent: parent, Fields: []FieldDiff{
+				{"value", k.Value, s.Value},
+			}})
+		}
+	}
+
+	if !enforce {
+		return
+	}
+	for _, k := range live {
+		if wanted[strings.ToUpper(k.Name)] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: resource, Name: k.Name, Parent: parent, Fields: []FieldDiff{
+			{"value", k.Value, nil},
+		}})
+	}
+}
+
+// planEnvironments matches environments by name, ignoring case. The
+// secrets, variables and branch patterns of a live environment are listed
+// only when the config has a list for them; those of a new environment are
+// all added after it is created. Removing an environment removes everything
+// in it.
+func planEnvironments(client *Client, plan *Plan, config config.C, live []Environment, enforce bool) error {
+	wanted := make(map[string]bool, len(config.Environments))
+	for _, env := range config.Environments {
+		wanted[strings.ToLower(env.Name)] = true
+
+		var k *Environment
+		for i := range live {
+			if strings.EqualFold(live[i].Name, env.Name) {
+				k = &live[i]
+			}
+		}
+		planEnvironment(plan, env, k)
+
+		name := env.Name
+		var patterns []DeploymentBranchPolicy
+		var secrets []SecretInfo
+		var variables []Variable
+		if k != nil {
+			name = k.Name
+			var err error
+			if env.BranchPatterns != nil && k.DeploymentBranches() == "custom" {
+				if patterns, err = ListDeploymentBranchPolicies(client, plan.Org, plan.Repository, name); err != nil {
+					return err
+				}
+			}
+			if env.Secrets != nil {
+				if secrets, err = ListEnvironmentSecrets(client, plan.Org, plan.Repository, name); err != nil {
+					return err
+				}
+			}
+			if env.Variables != nil {
+				if variables, err = ListEnvironmentVariables(client, plan.Org, plan.Repository, name); err != nil {
+					return err
+				}
+			}
+		}
+		if env.BranchPatterns != nil {
+			planBranchPatterns(plan, name, env.BranchPatterns, patterns, enforce)
+		}
+		if env.Secrets != nil {
+			if err := planSecretList(plan, "environment_secret", name, config.Repository.Name, env.Secrets, secrets, enforce); err != nil {
+				return err
+			}
+		}
+		if env.Variables != nil {
+			planVariableList(plan, "environment_variable", name, env.Variables, variables, enforce)
+		}
+	}
+
+	if !enforce {
+		return nil
+	}
+	for _, k := range live {
+		if wanted[strings.ToLower(k.Name)] {
+			continue
+		}
+		users, teams := k.ReviewerNames()
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "environment", Name: k.Name, Fields: []FieldDiff{
+			{"wait_timer", k.WaitTimer(), nil},
+			{"reviewers.users", users, nil},
+			{"reviewers.teams", teams, nil},
+			{"deployment_branches", k.DeploymentBranches(), nil},
+		}})
+	}
+	return nil
+}
+
+// planEnvironment compares the protection rules of an environment with the
+// config. live is nil for a new environment. Reviewers are compared by login
+// and team name or slug, ignoring case.
+func planEnvironment(plan *Plan, env config.Environment, live *Environment) {
+	var users, teams []string
+	if env.Reviewers != nil {
+		users, teams = env.Reviewers.Users, env.Reviewers.Teams
+	}
+
+	if live == nil {
+		plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Resource: "environment", Name: env.Name, Fields: specified([]FieldDiff{
+			{"wait_timer", nil, env.WaitTimer},
+			{"reviewers.users", nil, users},
+			{"reviewers.teams", nil, teams},
+			{"deployment_branches", nil, env.DeploymentBranches},
+		})})
+		return
+	}
+
+	liveUsers, liveTeams := live.ReviewerNames()
+	users = matchNames(users, liveUsers, nil)
+	teams = matchNames(teams, liveTeams, live.Reviewers("Team"))
+	fields := diffFields(specified([]FieldDiff{
+		{"wait_timer", live.WaitTimer(), env.WaitTimer},
+		{"reviewers.users", liveUsers, users},
+		{"reviewers.teams", liveTeams, teams},
+		{"deployment_branches", live.DeploymentBranches(), env.DeploymentBranches},
+	}))
+	if len(fields) > 0 {
+		plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: "environment", Name: live.Name, Fields: fields})
+	}
+}
+
+// matchNames replaces every name that matches a live name, ignoring case, by
+// the live name so that equal lists compare equal. Team names are matched
+// with the live team slugs.
+func matchNames(names []string, live []string, teams []EnvironmentReviewer) []string {
+	if names == nil {
+		return nil
+	}
+	matched := make([]string, len(names))
+	for i, n := range names {
+		matched[i] = n
+		for _, l := range live {
+			if strings.EqualFold(n, l) {
+				matched[i] = l
+			}
+		}
+		for _, t := range teams {
+			if strings.EqualFold(n, t.Reviewer.Name) {
+				matched[i] = t.Reviewer.Slug
+			}
+		}
+	}
+	return matched
+}
+
+// planBranchPatterns adds the deployment branch patterns of an environment
+// that are missing. With enforce, unlisted patterns are removed.
+func planBranchPatterns(plan *Plan, env string, patterns []string, live []DeploymentBranchPolicy, enforce bool) {
+	wanted := make(map[string]bool, len(patterns))
+	for _, p := range patterns {
+		wanted[p] = true
+		found := false
+		for _, k := range live {
+			found = found || k.Name == p
+		}
+		if !found {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Resource: "environment_branch_pattern", Name: p, Parent: env})
+		}
+	}
+
+	if !enforce {
+		return
+	}
+	for _, k := range live {
+		if !wanted[k.Name] {
+			plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "environment_branch_pattern", Name: k.Name, Parent: env, ID: k.ID})
+		}
+	}
+}
+
+// planRulesets matches rulesets by name, ignoring case. Rulesets inherited
+// from the organisation are never listed, so enforce only removes those of
+// the repository. Each rule is a field of its own named rules.<type>; rule
+// types ghsettings does not model are left alone.
+func planRulesets(client *Client, plan *Plan, config config.C, live []Ruleset, enforce bool) error {
+	teams := map[int64]string{}
+	teamIDs := map[string]int64{}
+	var orgTeams []OrgTeam
+	for _, r := range config.Rulesets {
+		for _, a := range r.BypassActors {
+			if a.Team == "" || teamIDs[strings.ToLower(a.Team)] != 0 {
+				continue
+			}
+			if orgTeams == nil {
+				var err error
+				if orgTeams, err = ListOrgTeams(client, plan.Org); err != nil {
+					return fmt.Errorf("looking up bypass teams: %s", err)
+				}
+			}
+			t := MatchTeam(orgTeams, a.Team)
+			if t == nil {
+				return fmt.Errorf("ruleset %s: bypass team %s not found in %s", r.Name, a.Team, plan.Org)
+			}
+			teams[int64(t.ID)] = t.Slug
+			teamIDs[strings.ToLower(a.Team)] = int64(t.ID)
+		}
+	}
+
+	wanted := make(map[int64]bool, len(config.Rulesets))
+	for _, r := range config.Rulesets {
+		var k *Ruleset
+		for i := range live {
+			if strings.EqualFold(live[i].Name, r.Name) {
+				k = &live[i]
+			}
+		}
+
+		var actors []BypassActor
+		if r.BypassActors != nil {
+			actors = []BypassActor{}
+			for _, a := range r.BypassActors {
+				actors = append(actors, bypassActor(a, teamIDs, teams))
+			}
+		}
+
+		if k == nil {
+			target, enforcement := "branch", "active"
+			if r.Target != nil {
+				target = *r.Target
+			}
+			if r.Enforcement != nil {
+				enforcement = *r.Enforcement
+			}
+			fields := specified([]FieldDiff{
+				{"target", nil, target},
+				{"enforcement", nil, enforcement},
+				{"include", nil, refPatterns(target, r.Include)},
+				{"exclude", nil, refPatterns(target, r.Exclude)},
+				{"bypass_actors", nil, actors},
+			})
+			fields = append(fields, ruleFields(r.Rules, nil, false)...)
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Resource: "ruleset", Name: r.Name, Fields: fields})
+			continue
+		}
+
+		wanted[k.ID] = true
+		k, err := GetRuleset(client, plan.Org, plan.Repository, fmt.Sprint(k.ID))
+		if err != nil {
+			return err
+		}
+		target := k.Target
+		if r.Target != nil {
+			target = *r.Target
+		}
+		fields := diffFields(specified([]FieldDiff{
+			{"target", k.Target, r.Target},
+			{"enforcement", k.Enforcement, r.Enforcement},
+			{"include", nonNil(k.Conditions.RefName.Include), refPatterns(target, r.Include)},
+			{"exclude", nonNil(k.Conditions.RefName.Exclude), refPatterns(target, r.Exclude)},
+		}))
+		for i := range k.BypassActors {
+			describeActor(&k.BypassActors[i], teams)
+		}
+		if actors != nil && !equalActors(k.BypassActors, actors) {
+			fields = append(fields, FieldDiff{"bypass_actors", k.BypassActors, actors})
+		}
+		fields = append(fields, ruleFields(r.Rules, k.Rules, enforce)...)
+		if len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: "ruleset", Name: k.Name, Fields: fields, ID: k.ID})
+		}
+	}
+
+	if !enforce {
+		return nil
+	}
+	for _, k := range live {
+		if wanted[k.ID] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "ruleset", Name: k.Name, ID: k.ID, Fields: []FieldDiff{
+			{"target", k.Target, nil},
+			{"enforcement", k.Enforcement, nil},
+		}})
+	}
+	return nil
+}
+
+// bypassActor returns the API form of a bypass actor in the config. Team
+// slugs are looked up in teamIDs.
+func bypassActor(a config.BypassActor, teamIDs map[string]int64, teams map[int64]string) BypassActor {
+	actor := BypassActor{BypassMode: "always"}
+	if a.Mode != nil {
+		actor.BypassMode = *a.Mode
+	}
+	id := int64(1)
+	switch {
+	case a.Team != "":
+		actor.ActorType, id = "Team", teamIDs[strings.ToLower(a.Team)]
+	case a.Role != nil && *a.Role == "org_admin":
+		actor.ActorType = "OrganizationAdmin"
+	case a.Role != nil:
+		actor.ActorType, id = "RepositoryRole", repositoryRoles[*a.Role]
+	case a.AppID != nil:
+		actor.ActorType, id = "Integration", int64(*a.AppID)
+	default:
+		actor.ActorType = "DeployKey"
+	}
+	if actor.ActorType != "DeployKey" {
+		actor.ActorID = &id
+	}
+	describeActor(&actor, teams)
+	return actor
+}
+
+// equalActors compares two lists of described bypass actors, ignoring order
+func equalActors(a, b []BypassActor) bool {
+	names := func(actors []BypassActor) []string {
+		list := []string{}
+		for _, k := range actors {
+			list = append(list, k.String())
+		}
+		return list
+	}
+	return equalValues(names(a), names(b))
+}
+
+// refPatterns turns the branch or tag names of the config into the full ref
+// patterns GitHub stores. ~DEFAULT_BRANCH, ~ALL and full refs are kept.
+func refPatterns(target string, patterns []string) []string {
+	if patterns == nil {
+		return nil
+	}
+	prefix := "refs/heads/"
+	if target == "tag" {
+		prefix = "refs/tags/"
+	}
+	refs := make([]string, len(patterns))
+	for i, p := range patterns {
+		refs[i] = p
+		if !strings.HasPrefix(p, "~") && !strings.HasPrefix(p, "refs/") {
+			refs[i] = prefix + p
+		}
+	}
+	return refs
+}
+
+// ruleFields compares the rules of the config with the live rules. The
+// parameters of a rule are the live ones with those of the config applied on
+// top, so parameters ghsettings does not model are kept. With enforce, the
+// modelled rules left out of the config are removed.
+func ruleFields(rules *config.Rules, live []Rule, enforce bool) []FieldDiff {
+	if rules == nil {
+		rules = &config.Rules{}
+	}
+	liveRule := func(kind string) interface{} {
+		for _, r := range live {
+			if r.Type == kind {
+				if len(r.Parameters) == 0 {
+					r.Parameters = nil
+				}
+				return r
+			}
+		}
+		return nil
+	}
+	params := func(kind string, defaults map[string]interface{}) map[string]interface{} {
+		p := map[string]interface{}{}
+		if r, ok := liveRule(kind).(Rule); ok {
+			defaults = r.Parameters
+		}
+		for k, v := range defaults {
+			p[k] = v
+		}
+		return p
+	}
+
+	// desired holds the wanted rule of each modelled type, nil to remove
+	// it, or is missing the type to leave it unchanged
+	desired := map[string]interface{}{}
+	if pr := rules.PullRequest; pr != nil {
+		p := params("pull_request", map[string]interface{}{
+			"required_approving_review_count":   0,
+			"dismiss_stale_reviews_on_push":     false,
+			"require_code_owner_review":         false,
+			"require_last_push_approval":        false,
+			"required_review_thread_resolution": false,
+		})
+		for _, f := range specified([]FieldDiff{
+			{"required_approving_review_count", nil, pr.RequiredApprovingReviewCount},
+			{"dismiss_stale_reviews_on_push", nil, pr.DismissStaleReviewsOnPush},
+			{"require_code_owner_review", nil, pr.RequireCodeOwnerReview},
+			{"require_last_push_approval", nil, pr.RequireLastPushApproval},
+			{"required_review_thread_resolution", nil, pr.RequiredReviewThreadResolution},
+		}) {
+			p[f.Name] = f.New
+		}
+		desired["pull_request"] = Rule{Type: "pull_request", Parameters: p}
+	}
+	if sc := rules.RequiredStatusChecks; sc != nil {
+		p := params("required_status_checks", map[string]interface{}{
+			"strict_required_status_checks_policy": false,
+			"required_status_checks":               []interface{}{},
+		})
+		if sc.Strict != nil {
+			p["strict_required_status_checks_policy"] = *sc.Strict
+		}
+		if sc.Contexts != nil {
+			checks := []interface{}{}
+			for _, c := range sc.Contexts {
+				var check interface{} = map[string]interface{}{"context": c}
+				if l, ok := p["required_status_checks"].([]interface{}); ok {
+					for _, k := range l {
+						if m, ok := k.(map[string]interface{}); ok && m["context"] == c {
+							check = k
+						}
+					}
+				}
+				checks = append(checks, check)
+			}
+			p["required_status_checks"] = checks
+		}
+		desired["required_status_checks"] = Rule{Type: "required_status_checks", Parameters: p}
+	}
+	for kind, set := range map[string]*bool{
+		"required_signatures":     rules.RequiredSignatures,
+		"non_fast_forward":        rules.NonFastForward,
+		"deletion":                rules.Deletion,
+		"required_linear_history": rules.RequiredLinearHistory,
+	} {
+		if set == nil {
+			continue
+		}
+		desired[kind] = nil
+		if *set {
+			desired[kind] = Rule{Type: kind}
+		}
+	}
+
+	var fields []FieldDiff
+	for _, kind := range []string{"pull_request", "required_status_checks", "required_signatures", "non_fast_forward", "deletion", "required_linear_history"} {
+		old := liveRule(kind)
+		rule, ok := desired[kind]
+		if !ok && !enforce {
+			continue
+		}
+		if r, isRule := rule.(Rule); isRule && r.Parameters != nil {
+			// round trip the parameters so numbers compare as float64
+			j, _ := json.Marshal(r.Parameters)
+			r.Parameters = nil
+			json.Unmarshal(j, &r.Parameters)
+			rule = r
+		}
+		if !reflect.DeepEqual(old, rule) {
+			fields = append(fields, FieldDiff{"rules." + kind, old, rule})
+		}
+	}
+	return fields
+}
+
+// planOrgVariables matches organisation variables by name like
+// planVariables. selected holds the repositories of every live variable with
+// selected visibility. New variables are private unless a visibility is set.
+func planOrgVariables(plan *Plan, config config.Org, live []Variable, selected map[string][]string, enforce bool) {
+	wanted := make(map[string]bool, len(config.Variables))
+	for _, s := range config.Variables {
+		wanted[strings.ToUpper(s.Name)] = true
+
+		var k *Variable
+		for i := range live {
+			if strings.EqualFold(live[i].Name, s.Name) {
+				k = &live[i]
+			}
+		}
+		if k == nil {
+			visibility := "private"
+			if s.Visibility != nil {
+				visibility = *s.Visibility
+			}
+			fields := []FieldDiff{
+				{"value", nil, s.Value},
+				{"visibility", nil, visibility},
+			}
+			if visibility == "selected" {
+				fields = append(fields, FieldDiff{"repositories", nil, nonNil(s.Repositories)})
+			}
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, Resource: "org_variable", Name: s.Name, Fields: fields})
+			continue
+		}
+
+		fields := diffFields(specified([]FieldDiff{
+			{"value", k.Value, s.Value},
+			{"visibility", k.Visibility, s.Visibility},
+		}))
+		visibility := k.Visibility
+		if s.Visibility != nil {
+			visibility = *s.Visibility
+		}
+		if visibility == "selected" && (s.Repositories != nil || k.Visibility != "selected") {
+			fields = append(fields, diffFields([]FieldDiff{
+				{"repositories", nonNil(selected[strings.ToUpper(k.Name)]), nonNil(s.Repositories)},
+			})...)
+		}
+		if len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionChange, Resource: "org_variable", Name: k.Name, Fields: fields})
+		}
+	}
+
+	if !enforce {
+		return
+	}
+	for _, k := range live {
+		if wanted[strings.ToUpper(k.Name)] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionRemove, Resource: "org_variable", Name: k.Name, Fields: []FieldDiff{
+			{"value", k.Value, nil},
+			{"visibility", k.Visibility, nil},
+		}})
+	}
+}
+
+// nonNil returns list, or an empty list when it is nil
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// branchFields pairs each branch protection setting in the config with its
+// live value. The field names are the GraphQL input names so a change can be
+// sent as is. When live is nil every old value is nil.
+func branchFields(live *BranchProtectionRule, s config.Branch) []FieldDiff {
+	fields := []FieldDiff{
+		{"requiresApprovingReviews", nil, s.RequiresApprovingReviews},
+		{"requiredApprovingReviewCount", nil, s.RequiredApprovingReviewCount},
+		{"dismissesStaleReviews", nil, s.DismissesStaleReviews},
+		{"requiresCodeOwnerReviews", nil, s.RequiresCodeOwnerReviews},
+		{"requiresStatusChecks", nil, s.RequiresStatusChecks},
+		{"requiredStatusCheckContexts", nil, s.RequiredStatusCheckContexts},
+		{"requiresStrictStatusChecks", nil, s.RequiresStrictStatusChecks},
+		{"requiresCommitSignatures", nil, s.RequiresCommitSignatures},
+		{"restrictsPushes", nil, s.RestrictsPushes},
+		{"pushActorIds", nil, s.PushActorIds},
+		{"isAdminEnforced", nil, s.IsAdminEnforced},
+	}
+	if live == nil {
+		return specified(fields)
+	}
+	old := []interface{}{
+		live.RequiresApprovingReviews,
+		live.RequiredApprovingReviewCount,
+		live.DismissesStaleReviews,
+		live.RequiresCodeOwnerReviews,
+		live.RequiresStatusChecks,
+		live.RequiredStatusCheckContexts,
+		live.RequiresStrictStatusChecks,
+		live.RequiresCommitSignatures,
+		live.RestrictsPushes,
+		live.PushActorIds(),
+		live.IsAdminEnforced,
+	}
+	for i := range fields {
+		fields[i].Old = old[i]
+	}
+	return specified(fields)
+}
+
+// specified drops the fields left out of the config, which are nil, nil
+// pointers or nil slices, and dereferences the remaining pointers.
+func specified(fields []FieldDiff) []FieldDiff {
+	var set []FieldDiff
+	for _, f := range fields {
+		v := reflect.ValueOf(f.New)
+		switch v.Kind() {
+		case reflect.Invalid:
+			continue
+		case reflect.Ptr:
+			if v.IsNil() {
+				continue
+			}
+			f.New = v.Elem().Interface()
+		case reflect.Slice:
+			if v.IsNil() {
+				continue
+			}
+		}
+		set = append(set, f)
+	}
+	return set
+}
+
+// diffFields returns the fields whose old and new values differ. String
+// slices are compared as sets since GitHub does not preserve their order.
+func diffFields(fields []FieldDiff) []FieldDiff {
+	var changed []FieldDiff
+	for _, f := range fields {
+		if !equalValues(f.Old, f.New) {
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
+
+func equalValues(a, b interface{}) bool {
+	sa, aok := a.([]string)
+	sb, bok := b.([]string)
+	if aok && bok {
+		if len(sa) != len(sb) {
+			return false
+		}
+		ca := append([]string(nil), sa...)
+		cb := append([]string(nil), sb...)
+		sort.Strings(ca)
+		sort.Strings(cb)
+		return strings.Join(ca, "\x00") == strings.Join(cb, "\x00")
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func contains(list []string, s string) bool {
+	for _, k := range list {
+		if k == s {
+			return true
+		}
+	}
+	return false
+}
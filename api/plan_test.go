@@ -0,0 +1,369 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mkrakowitzer/ghsettings/config"
+	"gopkg.in/yaml.v2"
+)
+
+func TestSpecified(t *testing.T) {
+	var nilList []string
+	fields := specified([]FieldDiff{
+		{"description", "old", config.String("new")},
+		{"homepage", "old", (*string)(nil)},
+		{"private", false, nil},
+		{"topics", []string{"go"}, nilList},
+		{"cleared", []string{"go"}, []string{}},
+		{"value", nil, Secret("s")},
+	})
+	got := fmt.Sprint(fields)
+	want := "[{description old new} {cleared [go] []} {value <nil> ********}]"
+	if got != want {
+		t.Errorf("specified() = %s, want %s", got, want)
+	}
+}
+
+func TestDiffFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     interface{}
+		new     interface{}
+		changed bool
+	}{
+		{"same string", "push", "push", false},
+		{"other string", "push", "admin", true},
+		{"same bool", true, true, false},
+		{"other bool", true, false, true},
+		{"list in another order", []string{"ci", "lint"}, []string{"lint", "ci"}, false},
+		{"list with duplicates", []string{"ci", "ci"}, []string{"ci", "lint"}, true},
+		{"shorter list", []string{"ci", "lint"}, []string{"ci"}, true},
+		{"empty lists", []string{}, []string{}, false},
+		{"new field", nil, "push", true},
+		{"removed field", "push", nil, true},
+	}
+	for _, tt := range tests {
+		fields := diffFields([]FieldDiff{{tt.name, tt.old, tt.new}})
+		if changed := len(fields) > 0; changed != tt.changed {
+			t.Errorf("diffFields(%s) reports a change %v, want %v", tt.name, changed, tt.changed)
+		}
+	}
+}
+
+// readConfig decodes a repository config, failing the test when it is invalid
+func readConfig(t *testing.T, data string) config.C {
+	var c config.C
+	if err := yaml.UnmarshalStrict([]byte(data), &c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// decodeLive decodes a canned API response into v
+func decodeLive(t *testing.T, data string, v interface{}) {
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// changeLines describes every change of a plan on a line of its own, e.g.
+// "~ team Platform permission: pull -> push"
+func changeLines(plan *Plan) string {
+	var lines []string
+	for _, c := range plan.Changes {
+		line := fmt.Sprintf("%s %s %s", c.Action, c.Resource, c.Name)
+		for _, f := range c.Fields {
+			line += fmt.Sprintf(" %s: %v -> %v", f.Name, f.Old, f.New)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestPlanRepository(t *testing.T) {
+	live := &RepositoryInfo{Name: "r", Description: "Service", Private: true, HasWiki: true, DefaultBranch: "main"}
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"nothing set", "repository: {name: r}", ""},
+		{"same values", "repository: {name: r, description: Service, private: true, has_wiki: true}", ""},
+		{"omitted settings are left alone", "repository: {name: r, has_wiki: false}",
+			"~ repository r has_wiki: true -> false"},
+		{"only differing settings are sent", "repository: {name: r, description: API, private: true, default_branch: trunk}",
+			"~ repository r description: Service -> API default_branch: main -> trunk"},
+	}
+	for _, tt := range tests {
+		plan := &Plan{}
+		planRepository(plan, readConfig(t, tt.config), live)
+		if got := changeLines(plan); got != tt.want {
+			t.Errorf("%s: planned\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlanTopics(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		live    []string
+		enforce bool
+		want    string
+	}{
+		{"no topics", "repository: {name: r}", []string{"go"}, true, ""},
+		{"added", "repository: {name: r, topics: [api]}", []string{"go"}, false, "~ topics r names: [go] -> [api go]"},
+		{"replaced with enforce", "repository: {name: r, topics: [api]}", []string{"go"}, true, "~ topics r names: [go] -> [api]"},
+		{"same in another order", "repository: {name: r, topics: [go, api]}", []string{"api", "go"}, true, ""},
+		{"cleared", "repository: {name: r, topics: []}", []string{"go"}, true, "~ topics r names: [go] -> []"},
+		{"first topics", "repository: {name: r, topics: [go]}", nil, false, "~ topics r names: [] -> [go]"},
+	}
+	for _, tt := range tests {
+		plan := &Plan{}
+		planTopics(plan, readConfig(t, tt.config), tt.live, tt.enforce)
+		if got := changeLines(plan); got != tt.want {
+			t.Errorf("%s: planned\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlanCollaborators(t *testing.T) {
+	var live Collaborators
+	decodeLive(t, `[{"login":"Owner","permissions":{"admin":true,"push":true,"pull":true}},
+		{"login":"dev","permissions":{"push":true,"pull":true}},
+		{"login":"alice","permissions":{"triage":true,"pull":true}},
+		{"login":"old","permissions":{"pull":true}}]`, &live)
+	cfg := readConfig(t, `
+collaborators:
+  - {username: dev, permission: maintain}
+  - {username: Alice, permission: triage}
+  - {username: new, permission: triage}
+`)
+	tests := []struct {
+		enforce bool
+		want    string
+	}{
+		{false, "~ collaborator dev permission: push -> maintain\n+ collaborator new permission: <nil> -> triage"},
+		{true, "~ collaborator dev permission: push -> maintain\n+ collaborator new permission: <nil> -> triage\n- collaborator old permission: pull -> <nil>"},
+	}
+	for _, tt := range tests {
+		plan := &Plan{}
+		planCollaborators(plan, cfg, live, []string{"owner"}, tt.enforce)
+		if got := changeLines(plan); got != tt.want {
+			t.Errorf("enforce %v: planned\n%s\nwant\n%s", tt.enforce, got, tt.want)
+		}
+	}
+}
+
+func TestPlanTeams(t *testing.T) {
+	var live Teams
+	decodeLive(t, `[{"name":"Platform","slug":"platform","permission":"pull"},
+		{"name":"Site Reliability","slug":"site-reliability","permission":"admin"},
+		{"name":"Platform Team","slug":"platform-team","permission":"push"},
+		{"name":"Docs","slug":"docs","permission":"triage"}]`, &live)
+	cfg := readConfig(t, `
+teams:
+  - {name: Platform, permission: push}
+  - {name: site-reliability, permission: admin}
+  - {name: platform team, permission: push}
+  - {name: SECURITY, permission: pull}
+`)
+	plan := &Plan{}
+	planTeams(plan, cfg, live, true)
+	want := "~ team Platform permission: pull -> push\n+ team SECURITY permission: <nil> -> pull\n- team Docs permission: triage -> <nil>"
+	if got := changeLines(plan); got != want {
+		t.Errorf("planned\n%s\nwant\n%s", got, want)
+	}
+	if id := plan.Changes[0].ID; id != "platform" {
+		t.Errorf("team changed by %v, want its slug", id)
+	}
+}
+
+func TestPlanLabels(t *testing.T) {
+	var live Labels
+	decodeLive(t, `[{"name":"BUG","color":"D73A4A","description":"Something is broken"},
+		{"name":"enhancement","color":"a2eeef","description":""},
+		{"name":"wontfix","color":"ffffff","description":""}]`, &live)
+	cfg := readConfig(t, `
+labels:
+  - {name: bug, color: "#d73a4a", description: Something is broken}
+  - {name: feature, oldname: enhancement}
+  - {name: triage, color: ededed}
+`)
+	plan := &Plan{}
+	planLabels(plan, cfg, live, true)
+	want := "~ label bug new_name: BUG -> bug\n~ label feature new_name: enhancement -> feature\n+ label triage color: <nil> -> ededed\n- label wontfix color: ffffff -> <nil>"
+	if got := changeLines(plan); got != want {
+		t.Errorf("planned\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPlanWebhooks(t *testing.T) {
+	os.Setenv("GHSETTINGS_TEST_HOOK_SECRET", "s3cret")
+	defer os.Unsetenv("GHSETTINGS_TEST_HOOK_SECRET")
+	var live Webhooks
+	decodeLive(t, `[{"id":1,"active":true,"events":["push","release"],
+			"config":{"url":"https://ci.example.com/hook","content_type":"json","insecure_ssl":"0","secret":"********"}},
+		{"id":2,"active":true,"events":["push"],"config":{"url":"https://chat.example.com/hook","content_type":"form","insecure_ssl":"0"}},
+		{"id":3,"active":false,"events":["push"],"config":{"url":"https://old.example.com/hook","content_type":"form","insecure_ssl":"0"}}]`, &live)
+
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "unchanged",
+			config: "webhooks: [{url: 'https://chat.example.com/hook', events: [push]}]",
+			want:   "- webhook https://ci.example.com/hook events: [push release] -> <nil>\n- webhook https://old.example.com/hook events: [push] -> <nil>",
+		},
+		{
+			name: "changed, with a secret that is always written",
+			config: `
+webhooks:
+  - {url: 'https://ci.example.com/hook', events: [release, push], secret_env: GHSETTINGS_TEST_HOOK_SECRET}
+  - {url: 'https://chat.example.com/hook', content_type: json, insecure_ssl: false}
+  - {url: 'https://old.example.com/hook', active: true}
+  - {url: 'https://new.example.com/hook', secret_env: GHSETTINGS_TEST_HOOK_SECRET}
+`,
+			want: "~ webhook https://ci.example.com/hook secret: ******** -> ********\n" +
+				"~ webhook https://chat.example.com/hook content_type: form -> json\n" +
+				"~ webhook https://old.example.com/hook active: false -> true\n" +
+				"+ webhook https://new.example.com/hook events: <nil> -> [push] secret: <nil> -> ********",
+		},
+	}
+	for _, tt := range tests {
+		plan := &Plan{}
+		if err := planWebhooks(plan, readConfig(t, tt.config), live, true); err != nil {
+			t.Fatal(err)
+		}
+		if got := changeLines(plan); got != tt.want {
+			t.Errorf("%s: planned\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	cfg := readConfig(t, "webhooks: [{url: 'https://ci.example.com/hook', secret_env: GHSETTINGS_TEST_UNSET}]")
+	if err := planWebhooks(&Plan{}, cfg, live, false); err == nil {
+		t.Error("planned a webhook whose secret variable is not set")
+	}
+}
+
+func TestPlanDeployKeys(t *testing.T) {
+	const (
+		ci     = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOaO9M5EkXBPyZJyUQPyPgOgBMHXUVPt19mvLESoYKUZ"
+		deploy = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOAYlLTPabyFnxHhKRjzySDdsDiPVciLsdh7x8vBbGYI"
+	)
+	live := DeployKeys{
+		{ID: 1, Key: ci, Title: "ci", ReadOnly: true},
+		{ID: 2, Key: deploy, Title: "deploy", ReadOnly: true},
+	}
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "matched by fingerprint, ignoring the comment",
+			config: "deploy_keys: [{title: ci, key: '" + ci + " ci@example.com', read_only: true}]",
+			want:   "- deploy_key deploy key: SHA256:v+FIjLwwYj/vCQrwoy9he77vhV+yqlYMQHYxcHqmZMk -> <nil> read_only: true -> <nil>",
+		},
+		{
+			name:   "replaced when changed",
+			config: "deploy_keys: [{title: ci, key: '" + ci + "'}, {title: release, key: '" + deploy + "', read_only: false}]",
+			want: "- deploy_key deploy key: SHA256:v+FIjLwwYj/vCQrwoy9he77vhV+yqlYMQHYxcHqmZMk -> <nil> read_only: true -> <nil>\n" +
+				"+ deploy_key release key: <nil> -> SHA256:v+FIjLwwYj/vCQrwoy9he77vhV+yqlYMQHYxcHqmZMk read_only: <nil> -> false",
+		},
+	}
+	for _, tt := range tests {
+		plan := &Plan{}
+		planDeployKeys(plan, readConfig(t, tt.config), live, true)
+		if got := changeLines(plan); got != tt.want {
+			t.Errorf("%s: planned\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlanVariables(t *testing.T) {
+	live := []Variable{{Name: "REGION", Value: "eu"}, {Name: "STAGE", Value: "prod"}, {Name: "OLD", Value: "x"}}
+	cfg := readConfig(t, "variables: [{name: region, value: us}, {name: Stage, value: prod}, {name: NEW, value: n}]")
+	tests := []struct {
+		enforce bool
+		want    string
+	}{
+		{false, "~ variable REGION value: eu -> us\n+ variable NEW value: <nil> -> n"},
+		{true, "~ variable REGION value: eu -> us\n+ variable NEW value: <nil> -> n\n- variable OLD value: x -> <nil>"},
+	}
+	for _, tt := range tests {
+		plan := &Plan{}
+		planVariables(plan, cfg, live, tt.enforce)
+		if got := changeLines(plan); got != tt.want {
+			t.Errorf("enforce %v: planned\n%s\nwant\n%s", tt.enforce, got, tt.want)
+		}
+	}
+}
+
+// TestPlanOrgVariables plans the organisation variables and applies the
+// plan, checking that selected repositories are sent by ID
+func TestPlanOrgVariables(t *testing.T) {
+	responses := map[string]string{
+		"GET /orgs/o/actions/variables": `{"total_count":4,"variables":[{"name":"REGION","value":"eu","visibility":"all"},
+			{"name":"TOKEN_URL","value":"u","visibility":"selected"},{"name":"DOCS","value":"d","visibility":"private"},
+			{"name":"OLD","value":"x","visibility":"private"}]}`,
+		"GET /orgs/o/actions/variables/TOKEN_URL/repositories": `{"total_count":1,"repositories":[{"name":"api"}]}`,
+		"GET /orgs/o/repos": `[{"id":11,"name":"api"},{"id":12,"name":"web"},{"id":13,"name":"docs"}]`,
+	}
+	var cfg config.Org
+	if err := yaml.UnmarshalStrict([]byte(`
+variables:
+  - {name: region, value: eu, visibility: private}
+  - {name: TOKEN_URL, value: u, repositories: [api, Web]}
+  - {name: docs, value: d, visibility: selected, repositories: [docs]}
+  - {name: NEW, value: n}
+  - {name: SHARED, value: s, visibility: selected}
+`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	client, fake := newFakeClient(responses)
+	plan, err := PlanOrganization(client, "o", cfg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "~ org_variable REGION visibility: all -> private\n" +
+		"~ org_variable TOKEN_URL repositories: [api] -> [api Web]\n" +
+		"~ org_variable DOCS visibility: private -> selected repositories: [] -> [docs]\n" +
+		"+ org_variable NEW value: <nil> -> n visibility: <nil> -> private\n" +
+		"+ org_variable SHARED value: <nil> -> s visibility: <nil> -> selected repositories: <nil> -> []\n" +
+		"- org_variable OLD value: x -> <nil> visibility: private -> <nil>"
+	if got := changeLines(plan); got != want {
+		t.Errorf("planned\n%s\nwant\n%s", got, want)
+	}
+
+	fake.Requests()
+	if err := ApplyPlan(client, plan); err != nil {
+		t.Fatal(err)
+	}
+	var sent []string
+	for _, r := range fake.Requests() {
+		if !strings.HasPrefix(r, "GET ") {
+			sent = append(sent, r)
+		}
+	}
+	want = `PATCH /orgs/o/actions/variables/REGION {"visibility":"private"}
+PATCH /orgs/o/actions/variables/TOKEN_URL {"selected_repository_ids":[11,12]}
+PATCH /orgs/o/actions/variables/DOCS {"selected_repository_ids":[13],"visibility":"selected"}
+POST /orgs/o/actions/variables {"name":"NEW","value":"n","visibility":"private"}
+POST /orgs/o/actions/variables {"name":"SHARED","selected_repository_ids":[],"value":"s","visibility":"selected"}
+DELETE /orgs/o/actions/variables/OLD`
+	if got := strings.Join(sent, "\n"); got != want {
+		t.Errorf("sent\n%s\nwant\n%s", got, want)
+	}
+
+	err = OrgVariableUpdate(client, "o", "TOKEN_URL", map[string]interface{}{"repositories": []string{"missing"}})
+	if err == nil {
+		t.Error("selected a repository that does not exist")
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestGetBranchProtectionRulesPages(t *testing.T) {
+	client, fake := newFakeClient(map[string]string{})
+	fake.Pages["POST /graphql"] = []string{
+		`{"data":{"organization":{"repository":{"branchProtectionRules":{
+			"pageInfo":{"hasNextPage":true,"endCursor":"C1"},"nodes":[{"id":"B1","pattern":"main"}]}}}}}`,
+		`{"data":{"organization":{"repository":{"branchProtectionRules":{
+			"pageInfo":{"hasNextPage":false,"endCursor":"C2"},"nodes":[{"id":"B2","pattern":"release/*"}]}}}}}`,
+	}
+
+	rules, err := GetBranchProtectionRules(client, "o", "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var patterns []string
+	for _, r := range rules.Organization.Repository.BranchProtectionRules.Nodes {
+		patterns = append(patterns, r.Pattern)
+	}
+	if got := strings.Join(patterns, ","); got != "main,release/*" {
+		t.Errorf("rules %s, want main,release/*", got)
+	}
+
+	var cursors []string
+	for _, r := range fake.Requests() {
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(r, "POST /graphql ")), &body); err != nil {
+			t.Fatal(err)
+		}
+		cursors = append(cursors, fmt.Sprint(body.Variables["cursor"]))
+	}
+	if got := strings.Join(cursors, ","); got != "<nil>,C1" {
+		t.Errorf("queried with the cursors %s, want none and then the end cursor of the first page", got)
+	}
+}
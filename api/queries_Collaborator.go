@@ -4,11 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
-
-	"github.com/mkrakowitzer/ghsettings/config"
-	"github.com/mkrakowitzer/ghsettings/utils"
-	"github.com/spf13/cobra"
 )
 
 type Collaborator struct {
@@ -16,38 +11,18 @@ type Collaborator struct {
 	Permission string `json:"permission"`
 }
 
-func UpdateCollaborator(apiClient *Client, config config.C, cmd *cobra.Command) error {
-
-	err := CollaboratorAddToRepo(apiClient, config)
-	if err != nil {
-		log.Fatal(err)
-	}
-	enforce, _ := cmd.PersistentFlags().GetBool("enforce")
-	if enforce {
-		err = CollaboratorRemoveFromRepo(apiClient, config)
-	}
-	return err
-}
+func CollaboratorAddToRepo(client *Client, org string, reponame string, username string, permission string) error {
 
-func CollaboratorAddToRepo(client *Client, config config.C) error {
+	path := fmt.Sprintf("repos/%s/%s/collaborators/%s", org, reponame, username)
+	result := Team{}
 
-	for _, s := range config.Collaborators {
-
-		path := fmt.Sprintf("repos/%s/%s/collaborators/%s", Org, config.Repository.Name, s.Username)
-		result := Team{}
-
-		collaborator := Collaborator{
-			Permission: s.Permission,
-		}
+	collaborator := Collaborator{
+		Permission: permission,
+	}
 
-		j, _ := json.Marshal(collaborator)
+	j, _ := json.Marshal(collaborator)
 
-		err := client.REST("PUT", path, bytes.NewBuffer(j), &result)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return client.REST("PUT", path, bytes.NewBuffer(j), &result)
 }
 
 type ListCollaborator struct {
@@ -55,56 +30,58 @@ type ListCollaborator struct {
 }
 
 type Collaborators []struct {
-	Login  string `json:"login"`
-	ID     int    `json:"id"`
-	NodeID string `json:"node_id"`
+	Login       string      `json:"login"`
+	ID          int         `json:"id"`
+	NodeID      string      `json:"node_id"`
+	Permissions Permissions `json:"permissions"`
 }
 
-// Refactor this, was in a hurry
-func CollaboratorRemoveFromRepo(client *Client, config config.C) error {
+// Permissions are the access flags GitHub reports for a collaborator
+type Permissions struct {
+	Admin    bool `json:"admin"`
+	Maintain bool `json:"maintain"`
+	Push     bool `json:"push"`
+	Triage   bool `json:"triage"`
+	Pull     bool `json:"pull"`
+}
 
-	admins, err := AdminList(client)
-	if err != nil {
-		return err
+// Name returns the highest permission level as used in the config file
+func (p Permissions) Name() string {
+	switch {
+	case p.Admin:
+		return "admin"
+	case p.Maintain:
+		return "maintain"
+	case p.Push:
+		return "push"
+	case p.Triage:
+		return "triage"
+	case p.Pull:
+		return "pull"
 	}
+	return ""
+}
+
+func ListCollaborators(client *Client, org string, reponame string) (Collaborators, error) {
 
-	path := fmt.Sprintf("repos/%s/%s/collaborators", Org, config.Repository.Name)
+	path := fmt.Sprintf("repos/%s/%s/collaborators?per_page=100", org, reponame)
 	result := Collaborators{}
 
-	err = client.REST("GET", path, &bytes.Buffer{}, &result)
-	if err != nil {
-		return err
-	}
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return result, err
+}
 
-	var gh_rules []string
-	var yml_rules []string
-	for _, k := range config.Collaborators {
-		yml_rules = append(yml_rules, k.Username)
-	}
-	for _, k := range result {
-		gh_rules = append(gh_rules, k.Login)
-	}
+func CollaboratorRemoveFromRepo(client *Client, org string, reponame string, login string) error {
 
-	delete := utils.Missing(admins, utils.Missing(yml_rules, gh_rules))
-
-	for _, k := range result {
-		for _, s := range delete {
-			if k.Login == s {
-				result1 := Teams{}
-				path := fmt.Sprintf("repos/%s/%s/collaborators/%s", Org, config.Repository.Name, k.Login)
-				err := client.REST("DELETE", path, &bytes.Buffer{}, &result1)
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}
-	return nil
+	result := Teams{}
+	path := fmt.Sprintf("repos/%s/%s/collaborators/%s", org, reponame, login)
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
 }
 
-func AdminList(client *Client) ([]string, error) {
+func AdminList(client *Client, org string) ([]string, error) {
 
-	path := fmt.Sprintf("orgs/%s/members?role=admin", Org)
+	path := fmt.Sprintf("orgs/%s/members?role=admin&per_page=100", org)
 	result := Collaborators{}
 
 	err := client.REST("GET", path, &bytes.Buffer{}, &result)
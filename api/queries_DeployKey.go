@@ -0,0 +1,67 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mkrakowitzer/ghsettings/config"
+)
+
+type DeployKey struct {
+	ID       int64  `json:"id"`
+	Key      string `json:"key"`
+	Title    string `json:"title"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+type DeployKeys []DeployKey
+
+// PublicKey is an SSH public key that is printed as its fingerprint
+type PublicKey string
+
+func (k PublicKey) String() string {
+	fp, err := config.KeyFingerprint(string(k))
+	if err != nil {
+		return "invalid key"
+	}
+	return fp
+}
+
+// ListDeployKeys returns every deploy key of a repository
+func ListDeployKeys(client *Client, org string, reponame string) (DeployKeys, error) {
+
+	path := fmt.Sprintf("repos/%s/%s/keys?per_page=100", org, reponame)
+	result := DeployKeys{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return result, err
+}
+
+// DeployKeyAddToRepo adds a deploy key with the given fields, key and
+// optionally read_only
+func DeployKeyAddToRepo(client *Client, org string, reponame string, title string, fields []FieldDiff) error {
+
+	path := fmt.Sprintf("repos/%s/%s/keys", org, reponame)
+	result := DeployKey{}
+
+	key := map[string]interface{}{"title": title}
+	for _, f := range fields {
+		if k, ok := f.New.(PublicKey); ok {
+			key[f.Name] = string(k)
+			continue
+		}
+		key[f.Name] = f.New
+	}
+	j, _ := json.Marshal(key)
+
+	return client.REST("POST", path, bytes.NewBuffer(j), &result)
+}
+
+func DeployKeyDeleteFromRepo(client *Client, org string, reponame string, id string) error {
+
+	path := fmt.Sprintf("repos/%s/%s/keys/%s", org, reponame, id)
+	result := DeployKey{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
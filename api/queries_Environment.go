@@ -0,0 +1,321 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Environment is a deployment environment as returned by the REST API
+type Environment struct {
+	ID                     int64            `json:"id"`
+	Name                   string           `json:"name"`
+	ProtectionRules        []ProtectionRule `json:"protection_rules"`
+	DeploymentBranchPolicy *struct {
+		ProtectedBranches    bool `json:"protected_branches"`
+		CustomBranchPolicies bool `json:"custom_branch_policies"`
+	} `json:"deployment_branch_policy"`
+}
+
+// ProtectionRule is a wait timer, required reviewers or branch policy rule
+// of an environment
+type ProtectionRule struct {
+	Type      string                `json:"type"`
+	WaitTimer int                   `json:"wait_timer"`
+	Reviewers []EnvironmentReviewer `json:"reviewers"`
+}
+
+// EnvironmentReviewer is a user or team required to approve deployments.
+// Name and Slug are only set for teams and Login only for users.
+type EnvironmentReviewer struct {
+	Type     string `json:"type"`
+	Reviewer struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+		Slug  string `json:"slug"`
+	} `json:"reviewer"`
+}
+
+// WaitTimer returns the minutes a deployment waits before it proceeds
+func (e *Environment) WaitTimer() int {
+	for _, r := range e.ProtectionRules {
+		if r.Type == "wait_timer" {
+			return r.WaitTimer
+		}
+	}
+	return 0
+}
+
+// Reviewers returns the required reviewers of the given type, User or Team
+func (e *Environment) Reviewers(kind string) []EnvironmentReviewer {
+	var reviewers []EnvironmentReviewer
+	for _, r := range e.ProtectionRules {
+		for _, k := range r.Reviewers {
+			if k.Type == kind {
+				reviewers = append(reviewers, k)
+			}
+		}
+	}
+	return reviewers
+}
+
+// ReviewerNames returns the logins of the required users and the slugs of
+// the required teams
+func (e *Environment) ReviewerNames() (users []string, teams []string) {
+	users, teams = []string{}, []string{}
+	for _, k := range e.Reviewers("User") {
+		users = append(users, k.Reviewer.Login)
+	}
+	for _, k := range e.Reviewers("Team") {
+		teams = append(teams, k.Reviewer.Slug)
+	}
+	return users, teams
+}
+
+// DeploymentBranches returns which branches can deploy: all, protected or
+// custom
+func (e *Environment) DeploymentBranches() string {
+	switch {
+	case e.DeploymentBranchPolicy == nil:
+		return "all"
+	case e.DeploymentBranchPolicy.CustomBranchPolicies:
+		return "custom"
+	}
+	return "protected"
+}
+
+// ListEnvironments returns the deployment environments of a repository
+func ListEnvironments(client *Client, org string, reponame string) ([]Environment, error) {
+	var environments []Environment
+	for page := 1; ; page++ {
+		var result struct {
+			TotalCount   int           `json:"total_count"`
+			Environments []Environment `json:"environments"`
+		}
+		p := fmt.Sprintf("repos/%s/%s/environments?per_page=100&page=%d", org, reponame, page)
+		if err := client.REST("GET", p, &bytes.Buffer{}, &result); err != nil {
+			return nil, err
+		}
+		environments = append(environments, result.Environments...)
+		if len(result.Environments) == 0 || len(environments) >= result.TotalCount {
+			return environments, nil
+		}
+	}
+}
+
+func GetEnvironment(client *Client, org string, reponame string, name string) (*Environment, error) {
+
+	path := fmt.Sprintf("repos/%s/%s/environments/%s", org, reponame, url.PathEscape(name))
+	result := Environment{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return &result, err
+}
+
+// EnvironmentAddToRepo creates an environment with the given settings.
+// Settings left out get the defaults of GitHub.
+func EnvironmentAddToRepo(client *Client, org string, reponame string, name string, fields []FieldDiff) error {
+	return putEnvironment(client, org, reponame, name, &Environment{}, fields)
+}
+
+// EnvironmentUpdate changes the given settings of an environment. GitHub
+// replaces every setting of an environment at once, so the others are read
+// back and sent unchanged.
+func EnvironmentUpdate(client *Client, org string, reponame string, name string, fields []FieldDiff) error {
+	live, err := GetEnvironment(client, org, reponame, name)
+	if err != nil {
+		return err
+	}
+	return putEnvironment(client, org, reponame, name, live, fields)
+}
+
+func EnvironmentDeleteFromRepo(client *Client, org string, reponame string, name string) error {
+
+	path := fmt.Sprintf("repos/%s/%s/environments/%s", org, reponame, url.PathEscape(name))
+	result := Environment{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
+
+// putEnvironment sends the settings of live with fields applied on top.
+// Reviewers are resolved from logins and team names or slugs to IDs.
+func putEnvironment(client *Client, org string, reponame string, name string, live *Environment, fields []FieldDiff) error {
+
+	type reviewer struct {
+		Type string `json:"type"`
+		ID   int64  `json:"id"`
+	}
+	body := map[string]interface{}{}
+	var users, teams []reviewer
+	for _, k := range live.Reviewers("User") {
+		users = append(users, reviewer{"User", k.Reviewer.ID})
+	}
+	for _, k := range live.Reviewers("Team") {
+		teams = append(teams, reviewer{"Team", k.Reviewer.ID})
+	}
+	if live.ID != 0 {
+		body["wait_timer"] = live.WaitTimer()
+		body["deployment_branch_policy"] = live.DeploymentBranchPolicy
+	}
+
+	for _, f := range fields {
+		switch f.Name {
+		case "wait_timer":
+			body["wait_timer"] = f.New
+		case "deployment_branches":
+			switch f.New {
+			case "all":
+				body["deployment_branch_policy"] = nil
+			case "protected":
+				body["deployment_branch_policy"] = map[string]bool{"protected_branches": true, "custom_branch_policies": false}
+			case "custom":
+				body["deployment_branch_policy"] = map[string]bool{"protected_branches": false, "custom_branch_policies": true}
+			}
+		case "reviewers.users":
+			users = []reviewer{}
+			for _, login := range f.New.([]string) {
+				u, err := GetUser(client, login)
+				if err != nil {
+					return fmt.Errorf("looking up reviewer %s: %s", login, err)
+				}
+				users = append(users, reviewer{"User", int64(u.ID)})
+			}
+		case "reviewers.teams":
+			teams = []reviewer{}
+			orgTeams, err := ListOrgTeams(client, org)
+			if err != nil {
+				return fmt.Errorf("looking up reviewer teams: %s", err)
+			}
+			for _, name := range f.New.([]string) {
+				t := MatchTeam(orgTeams, name)
+				if t == nil {
+					return fmt.Errorf("reviewer team %s not found in %s", name, org)
+				}
+				teams = append(teams, reviewer{"Team", int64(t.ID)})
+			}
+		}
+	}
+	if users != nil || teams != nil {
+		body["reviewers"] = append(append([]reviewer{}, users...), teams...)
+	}
+
+	path := fmt.Sprintf("repos/%s/%s/environments/%s", org, reponame, url.PathEscape(name))
+	result := Environment{}
+
+	j, _ := json.Marshal(body)
+
+	return client.REST("PUT", path, bytes.NewBuffer(j), &result)
+}
+
+// DeploymentBranchPolicy is a name pattern of the branches that can deploy
+// to an environment with custom branch policies
+type DeploymentBranchPolicy struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// ListDeploymentBranchPolicies returns the branch patterns of an environment
+func ListDeploymentBranchPolicies(client *Client, org string, reponame string, env string) ([]DeploymentBranchPolicy, error) {
+	var policies []DeploymentBranchPolicy
+	for page := 1; ; page++ {
+		var result struct {
+			TotalCount     int                      `json:"total_count"`
+			BranchPolicies []DeploymentBranchPolicy `json:"branch_policies"`
+		}
+		p := fmt.Sprintf("repos/%s/%s/environments/%s/deployment-branch-policies?per_page=100&page=%d", org, reponame, url.PathEscape(env), page)
+		if err := client.REST("GET", p, &bytes.Buffer{}, &result); err != nil {
+			return nil, err
+		}
+		policies = append(policies, result.BranchPolicies...)
+		if len(result.BranchPolicies) == 0 || len(policies) >= result.TotalCount {
+			return policies, nil
+		}
+	}
+}
+
+func DeploymentBranchPolicyAdd(client *Client, org string, reponame string, env string, pattern string) error {
+
+	path := fmt.Sprintf("repos/%s/%s/environments/%s/deployment-branch-policies", org, reponame, url.PathEscape(env))
+	result := DeploymentBranchPolicy{}
+
+	j, _ := json.Marshal(map[string]string{"name": pattern})
+
+	return client.REST("POST", path, bytes.NewBuffer(j), &result)
+}
+
+func DeploymentBranchPolicyDelete(client *Client, org string, reponame string, env string, id string) error {
+
+	path := fmt.Sprintf("repos/%s/%s/environments/%s/deployment-branch-policies/%s", org, reponame, url.PathEscape(env), id)
+	result := DeploymentBranchPolicy{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
+
+func environmentSecretsPath(org string, reponame string, env string) string {
+	return fmt.Sprintf("repos/%s/%s/environments/%s/secrets", org, reponame, url.PathEscape(env))
+}
+
+// ListEnvironmentSecrets returns the Actions secrets of an environment
+func ListEnvironmentSecrets(client *Client, org string, reponame string, env string) ([]SecretInfo, error) {
+	return listSecrets(client, environmentSecretsPath(org, reponame, env))
+}
+
+// GetEnvironmentSecret returns a single Actions secret of an environment
+func GetEnvironmentSecret(client *Client, org string, reponame string, env string, name string) (*SecretInfo, error) {
+
+	path := fmt.Sprintf("%s/%s", environmentSecretsPath(org, reponame, env), name)
+	result := SecretInfo{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return &result, err
+}
+
+// GetEnvironmentSecretPublicKey returns the key the Actions secrets of an
+// environment are sealed with. Every environment has a key of its own.
+func GetEnvironmentSecretPublicKey(client *Client, org string, reponame string, env string) (*SecretPublicKey, error) {
+	return getPublicKey(client, environmentSecretsPath(org, reponame, env))
+}
+
+// EnvironmentSecretUpdate encrypts value with key, the public key of the
+// environment, and creates or replaces the secret
+func EnvironmentSecretUpdate(client *Client, org string, reponame string, env string, name string, value Secret, key *SecretPublicKey) error {
+	return putSecret(client, environmentSecretsPath(org, reponame, env), name, value, key)
+}
+
+func EnvironmentSecretDelete(client *Client, org string, reponame string, env string, name string) error {
+
+	path := fmt.Sprintf("%s/%s", environmentSecretsPath(org, reponame, env), name)
+	result := SecretInfo{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
+
+func environmentVariablesPath(org string, reponame string, env string) string {
+	return fmt.Sprintf("repos/%s/%s/environments/%s/variables", org, reponame, url.PathEscape(env))
+}
+
+// ListEnvironmentVariables returns the Actions variables of an environment
+func ListEnvironmentVariables(client *Client, org string, reponame string, env string) ([]Variable, error) {
+	return listVariables(client, environmentVariablesPath(org, reponame, env))
+}
+
+// EnvironmentVariableAdd creates an Actions variable in an environment
+func EnvironmentVariableAdd(client *Client, org string, reponame string, env string, name string, value string) error {
+	return createVariable(client, environmentVariablesPath(org, reponame, env), name, value)
+}
+
+// EnvironmentVariableUpdate changes the value of an Actions variable in an
+// environment
+func EnvironmentVariableUpdate(client *Client, org string, reponame string, env string, name string, value string) error {
+	return updateVariable(client, environmentVariablesPath(org, reponame, env), name, value)
+}
+
+func EnvironmentVariableDelete(client *Client, org string, reponame string, env string, name string) error {
+
+	path := fmt.Sprintf("%s/%s", environmentVariablesPath(org, reponame, env), name)
+	result := Variable{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPlanEnvironments(t *testing.T) {
+	os.Setenv("GHSETTINGS_TEST_DEPLOY_KEY", "s3cret")
+	defer os.Unsetenv("GHSETTINGS_TEST_DEPLOY_KEY")
+	client, _ := newFakeClient(map[string]string{
+		"GET /repos/o/r/environments/production/deployment-branch-policies": `{"total_count":2,
+			"branch_policies":[{"id":31,"name":"main"},{"id":32,"name":"release/*"}]}`,
+		"GET /repos/o/r/environments/production/secrets":   `{"total_count":1,"secrets":[{"name":"DEPLOY_KEY"}]}`,
+		"GET /repos/o/r/environments/production/variables": `{"total_count":2,"variables":[{"name":"REGION","value":"eu"},{"name":"OLD","value":"x"}]}`,
+	})
+	var live []Environment
+	decodeLive(t, `[{"id":1,"name":"production","protection_rules":[{"type":"wait_timer","wait_timer":5},
+			{"type":"required_reviewers","reviewers":[{"type":"User","reviewer":{"id":7,"login":"octocat"}},
+				{"type":"Team","reviewer":{"id":21,"name":"Platform Team","slug":"platform-team"}}]}],
+			"deployment_branch_policy":{"protected_branches":false,"custom_branch_policies":true}},
+		{"id":2,"name":"staging","deployment_branch_policy":{"protected_branches":true,"custom_branch_policies":false}},
+		{"id":3,"name":"old"}]`, &live)
+	cfg := readConfig(t, `
+environments:
+  - name: Production
+    wait_timer: 5
+    reviewers: {users: [OCTOCAT], teams: [platform team]}
+    deployment_branches: custom
+    branch_patterns: [main, hotfix/*]
+    secrets: [{name: deploy_key, env: GHSETTINGS_TEST_DEPLOY_KEY}]
+    variables: [{name: region, value: us}]
+  - name: staging
+    deployment_branches: custom
+    branch_patterns: [main]
+  - name: preview
+    reviewers: {users: [alice]}
+    variables: [{name: URL, value: https://preview.example.com}]
+`)
+	tests := []struct {
+		enforce bool
+		want    string
+	}{
+		{false, "+ environment_branch_pattern hotfix/*\n" +
+			"~ environment_secret deploy_key value: ******** -> ********\n" +
+			"~ environment_variable REGION value: eu -> us\n" +
+			"~ environment staging deployment_branches: protected -> custom\n" +
+			"+ environment_branch_pattern main\n" +
+			"+ environment preview reviewers.users: <nil> -> [alice]\n" +
+			"+ environment_variable URL value: <nil> -> https://preview.example.com"},
+		{true, "+ environment_branch_pattern hotfix/*\n" +
+			"- environment_branch_pattern release/*\n" +
+			"~ environment_secret deploy_key value: ******** -> ********\n" +
+			"~ environment_variable REGION value: eu -> us\n" +
+			"- environment_variable OLD value: x -> <nil>\n" +
+			"~ environment staging deployment_branches: protected -> custom\n" +
+			"+ environment_branch_pattern main\n" +
+			"+ environment preview reviewers.users: <nil> -> [alice]\n" +
+			"+ environment_variable URL value: <nil> -> https://preview.example.com\n" +
+			"- environment old wait_timer: 0 -> <nil> reviewers.users: [] -> <nil> reviewers.teams: [] -> <nil> deployment_branches: all -> <nil>"},
+	}
+	for _, tt := range tests {
+		plan := &Plan{Org: "o", Repository: "r"}
+		if err := planEnvironments(client, plan, cfg, live, tt.enforce); err != nil {
+			t.Fatal(err)
+		}
+		if got := changeLines(plan); got != tt.want {
+			t.Errorf("enforce %v: planned\n%s\nwant\n%s", tt.enforce, got, tt.want)
+		}
+	}
+
+	plan := &Plan{Org: "o", Repository: "r"}
+	cfg = readConfig(t, "environments: [{name: production, reviewers: {users: [octocat, alice], teams: [security]}}]")
+	if err := planEnvironments(client, plan, cfg, live, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "~ environment production reviewers.users: [octocat] -> [octocat alice] reviewers.teams: [platform-team] -> [security]"
+	if got := changeLines(plan); got != want {
+		t.Errorf("planned\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestEnvironmentUpdate(t *testing.T) {
+	responses := map[string]string{
+		"GET /repos/o/r/environments/production": `{"id":1,"name":"production","protection_rules":[{"type":"wait_timer","wait_timer":5},
+			{"type":"required_reviewers","reviewers":[{"type":"User","reviewer":{"id":7,"login":"octocat"}},
+				{"type":"Team","reviewer":{"id":21,"name":"Platform Team","slug":"platform-team"}}]}],
+			"deployment_branch_policy":{"protected_branches":false,"custom_branch_policies":true}}`,
+		"GET /users/alice":  `{"login":"alice","id":42}`,
+		"GET /orgs/o/teams": `[{"id":21,"name":"Platform Team","slug":"platform-team"},{"id":22,"name":"Security","slug":"security"}]`,
+	}
+	tests := []struct {
+		name   string
+		fields []FieldDiff
+		want   string
+	}{
+		{
+			name:   "other settings are sent unchanged",
+			fields: []FieldDiff{{"wait_timer", 5, 10}},
+			want: `{"deployment_branch_policy":{"protected_branches":false,"custom_branch_policies":true},` +
+				`"reviewers":[{"type":"User","id":7},{"type":"Team","id":21}],"wait_timer":10}`,
+		},
+		{
+			name:   "reviewers are resolved to IDs",
+			fields: []FieldDiff{{"reviewers.users", []string{"octocat"}, []string{"alice"}}, {"reviewers.teams", []string{"platform-team"}, []string{"SECURITY"}}},
+			want: `{"deployment_branch_policy":{"protected_branches":false,"custom_branch_policies":true},` +
+				`"reviewers":[{"type":"User","id":42},{"type":"Team","id":22}],"wait_timer":5}`,
+		},
+		{
+			name:   "reviewers are cleared",
+			fields: []FieldDiff{{"reviewers.users", []string{"octocat"}, []string{}}, {"reviewers.teams", []string{"platform-team"}, []string{}}},
+			want:   `{"deployment_branch_policy":{"protected_branches":false,"custom_branch_policies":true},"reviewers":[],"wait_timer":5}`,
+		},
+	}
+	for _, tt := range tests {
+		client, fake := newFakeClient(responses)
+		if err := EnvironmentUpdate(client, "o", "r", "production", tt.fields); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		requests := fake.Requests()
+		put := requests[len(requests)-1]
+		if want := "PUT /repos/o/r/environments/production " + tt.want; put != want {
+			t.Errorf("%s: sent\n%s\nwant\n%s", tt.name, put, want)
+		}
+	}
+
+	for _, f := range []FieldDiff{{"reviewers.users", nil, []string{"ghost"}}, {"reviewers.teams", nil, []string{"docs"}}} {
+		client, _ := newFakeClient(responses)
+		if err := EnvironmentAddToRepo(client, "o", "r", "preview", []FieldDiff{f}); err == nil {
+			t.Errorf("created an environment with the unknown reviewer %v", f.New)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+type Label struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+type Labels []Label
+
+// ListLabels returns every label of a repository
+func ListLabels(client *Client, org string, reponame string) (Labels, error) {
+
+	path := fmt.Sprintf("repos/%s/%s/labels?per_page=100", org, reponame)
+	result := Labels{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return result, err
+}
+
+// LabelAddToRepo creates a label with the given fields, e.g. color and description
+func LabelAddToRepo(client *Client, org string, reponame string, name string, fields map[string]interface{}) error {
+
+	path := fmt.Sprintf("repos/%s/%s/labels", org, reponame)
+	result := Label{}
+
+	label := map[string]interface{}{"name": name}
+	for k, v := range fields {
+		label[k] = v
+	}
+	j, _ := json.Marshal(label)
+
+	return client.REST("POST", path, bytes.NewBuffer(j), &result)
+}
+
+// LabelUpdate changes the given fields of a label. Setting new_name renames it.
+func LabelUpdate(client *Client, org string, reponame string, name string, fields map[string]interface{}) error {
+
+	path := fmt.Sprintf("repos/%s/%s/labels/%s", org, reponame, url.PathEscape(name))
+	result := Label{}
+
+	j, _ := json.Marshal(fields)
+
+	return client.REST("PATCH", path, bytes.NewBuffer(j), &result)
+}
+
+func LabelDeleteFromRepo(client *Client, org string, reponame string, name string) error {
+
+	path := fmt.Sprintf("repos/%s/%s/labels/%s", org, reponame, url.PathEscape(name))
+	result := Label{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
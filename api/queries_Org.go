@@ -0,0 +1,58 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+type OrgMembership struct {
+	State string `json:"state"`
+	Role  string `json:"role"`
+}
+
+func GetOrgMembership(client *Client, org string, login string) (*OrgMembership, error) {
+
+	path := fmt.Sprintf("orgs/%s/memberships/%s", org, login)
+	result := OrgMembership{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return &result, err
+}
+
+type OrgTeam struct {
+	ID     int    `json:"id"`
+	NodeID string `json:"node_id"`
+	Name   string `json:"name"`
+	Slug   string `json:"slug"`
+}
+
+func GetTeam(client *Client, org string, slug string) (*OrgTeam, error) {
+
+	path := fmt.Sprintf("orgs/%s/teams/%s", org, slug)
+	result := OrgTeam{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return &result, err
+}
+
+// ListOrgTeams returns every team of the organisation
+func ListOrgTeams(client *Client, org string) ([]OrgTeam, error) {
+
+	path := fmt.Sprintf("orgs/%s/teams?per_page=100", org)
+	result := []OrgTeam{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return result, err
+}
+
+// MatchTeam returns the team whose slug or name is name, ignoring case, or
+// nil. Config files may name a team either way.
+func MatchTeam(teams []OrgTeam, name string) *OrgTeam {
+	for i := range teams {
+		if strings.EqualFold(teams[i].Slug, name) || strings.EqualFold(teams[i].Name, name) {
+			return &teams[i]
+		}
+	}
+	return nil
+}
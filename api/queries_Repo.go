@@ -4,68 +4,98 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-
-	"github.com/mkrakowitzer/ghsettings/config"
-	"github.com/shurcooL/githubv4"
 )
 
-type RepoPayload struct {
-	Organization struct {
-		Repository struct {
-			ID githubv4.ID
-		}
-	}
+// RepositoryInfo is the live state of a repository as returned by the REST API
+type RepositoryInfo struct {
+	NodeID              string   `json:"node_id"`
+	Name                string   `json:"name"`
+	Description         string   `json:"description"`
+	Homepage            string   `json:"homepage"`
+	Private             bool     `json:"private"`
+	HasIssues           bool     `json:"has_issues"`
+	HasProjects         bool     `json:"has_projects"`
+	HasWiki             bool     `json:"has_wiki"`
+	HasDownloads        bool     `json:"has_downloads"`
+	DefaultBranch       string   `json:"default_branch"`
+	AllowSquashMerge    bool     `json:"allow_squash_merge"`
+	AllowMergeCommit    bool     `json:"allow_merge_commit"`
+	AllowRebaseMerge    bool     `json:"allow_rebase_merge"`
+	DeleteBranchOnMerge bool     `json:"delete_branch_on_merge"`
+	Topics              []string `json:"topics"`
 }
 
-func GetRepoID(client *Client, org string, reponame string) (*RepoPayload, error) {
-	query := `
-	query($org: String!, $name: String!) {
-	  organization(login: $org) {
-	    repository(name: $name) {
-	      id
-	    }
-	  }
-	}`
-	variables := map[string]interface{}{"org": org, "name": reponame}
-	result := RepoPayload{}
-
-	err := client.GraphQL(query, variables, &result)
+func GetRepository(client *Client, org string, reponame string) (*RepositoryInfo, error) {
+
+	path := fmt.Sprintf("repos/%s/%s", org, reponame)
+	result := RepositoryInfo{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
 	return &result, err
 }
 
-func UpdateRepository(apiClient *Client, repo *RepoPayload, config config.C) error {
-	variables := map[string]interface{}{
-		"id":          repo.Organization.Repository.ID,
-		"homepage":    config.Repository.Homepage,
-		"issues":      config.Repository.HasIssues,
-		"wiki":        config.Repository.HasWiki,
-		"projects":    config.Repository.HasProjects,
-		"description": config.Repository.Description,
+type Repositories []struct {
+	ID       int64    `json:"id"`
+	Name     string   `json:"name"`
+	Archived bool     `json:"archived"`
+	Topics   []string `json:"topics"`
+}
+
+func ListRepositories(client *Client, org string) (Repositories, error) {
+
+	path := fmt.Sprintf("orgs/%s/repos?per_page=100", org)
+	result := Repositories{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return result, err
+}
+
+// repositoryV4Inputs maps config fields that are updated through the
+// updateRepository mutation to their GraphQL input names. All other
+// repository fields are sent to the REST API under their config name.
+var repositoryV4Inputs = map[string]string{
+	"description":  "description",
+	"homepage":     "homepageUrl",
+	"has_issues":   "hasIssuesEnabled",
+	"has_wiki":     "hasWikiEnabled",
+	"has_projects": "hasProjectsEnabled",
+}
+
+// UpdateRepository sends only the given fields, split between the GraphQL and
+// REST APIs. No request is made for an API with no fields to update.
+func UpdateRepository(apiClient *Client, org string, id string, reponame string, fields []FieldDiff) error {
+	input := map[string]interface{}{}
+	patch := map[string]interface{}{}
+	for _, f := range fields {
+		if name, ok := repositoryV4Inputs[f.Name]; ok {
+			input[name] = f.New
+		} else {
+			patch[f.Name] = f.New
+		}
 	}
 
-	err := UpdateRepositoryV4(apiClient, variables)
-	if err != nil {
-		return err
+	if len(input) > 0 {
+		input["repositoryId"] = id
+		err := UpdateRepositoryV4(apiClient, input)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = UpdateRepositoryV3(apiClient, repo, config)
-	return err
+	if len(patch) > 0 {
+		return UpdateRepositoryV3(apiClient, org, reponame, patch)
+	}
+	return nil
 }
 
-func UpdateRepositoryV4(client *Client, variables map[string]interface{}) error {
+func UpdateRepositoryV4(client *Client, input map[string]interface{}) error {
 	mutation := `
-	mutation ($id: ID!, $homepage: String!, $wiki: Boolean!, $projects: Boolean!, $issues: Boolean!, $description: String!) {	
-		updateRepository(input: {
-			repositoryId: $id,
-			homepageUrl: $homepage,
-			hasWikiEnabled: $wiki,
-			hasProjectsEnabled: $projects,
-			hasIssuesEnabled: $issues,
-			description: $description,
-		}) {
+	mutation ($input: UpdateRepositoryInput!) {
+		updateRepository(input: $input) {
 		  clientMutationId
 		}
 	  }`
+	variables := map[string]interface{}{"input": input}
 	err := client.GraphQL(mutation, variables, nil)
 	return err
 }
@@ -79,21 +109,12 @@ type Repository struct {
 	DeleteBranchOnMerge bool   `json:"delete_branch_on_merge"`
 }
 
-func UpdateRepositoryV3(client *Client, repo *RepoPayload, config config.C) error {
+func UpdateRepositoryV3(client *Client, org string, reponame string, patch map[string]interface{}) error {
 
-	path := fmt.Sprintf("repos/%s/%s", Org, config.Repository.Name)
+	path := fmt.Sprintf("repos/%s/%s", org, reponame)
 	result := Repository{}
 
-	updateRepo := Repository{
-		Private:             config.Repository.Private,
-		DefaultBranch:       config.Repository.DefaultBranch,
-		AllowRebaseMerge:    config.Repository.AllowRebaseMerge,
-		AllowSquashMerge:    config.Repository.AllowSquashMerge,
-		AllowMergeCommit:    config.Repository.AllowMergeCommit,
-		DeleteBranchOnMerge: config.Repository.DeleteBranchOnMerge,
-	}
-
-	j, _ := json.Marshal(updateRepo)
+	j, _ := json.Marshal(patch)
 
 	err := client.REST("PATCH", path, bytes.NewBuffer(j), &result)
 	return err
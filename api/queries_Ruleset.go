@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Ruleset is a repository ruleset as returned by the REST API. Rulesets
+// listed without their id only have ID, Name, Target and Enforcement set.
+type Ruleset struct {
+	ID           int64         `json:"id"`
+	Name         string        `json:"name"`
+	Target       string        `json:"target"`
+	Enforcement  string        `json:"enforcement"`
+	BypassActors []BypassActor `json:"bypass_actors"`
+	Conditions   struct {
+		RefName struct {
+			Include []string `json:"include"`
+			Exclude []string `json:"exclude"`
+		} `json:"ref_name"`
+	} `json:"conditions"`
+	Rules []Rule `json:"rules"`
+}
+
+// BypassActor can bypass a ruleset. Name describes the actor in plans, as
+// GitHub only returns its ID.
+type BypassActor struct {
+	ActorID    *int64 `json:"actor_id"`
+	ActorType  string `json:"actor_type"`
+	BypassMode string `json:"bypass_mode"`
+	Name       string `json:"-"`
+}
+
+func (a BypassActor) String() string {
+	return fmt.Sprintf("%s (%s)", a.Name, a.BypassMode)
+}
+
+// repositoryRoles are the actor IDs of the built in repository roles
+var repositoryRoles = map[string]int64{
+	"maintain": 2,
+	"write":    4,
+	"admin":    5,
+}
+
+// describeActor sets the name of an actor. teams maps the IDs of the teams
+// known to the plan to their slugs.
+func describeActor(a *BypassActor, teams map[int64]string) {
+	id := int64(0)
+	if a.ActorID != nil {
+		id = *a.ActorID
+	}
+	switch a.ActorType {
+	case "Team":
+		a.Name = fmt.Sprintf("team #%d", id)
+		if slug, ok := teams[id]; ok {
+			a.Name = "team " + slug
+		}
+	case "RepositoryRole":
+		a.Name = fmt.Sprintf("role #%d", id)
+		for role, k := range repositoryRoles {
+			if k == id {
+				a.Name = "role " + role
+			}
+		}
+	case "OrganizationAdmin":
+		a.Name = "role org_admin"
+	case "Integration":
+		a.Name = fmt.Sprintf("app %d", id)
+	case "DeployKey":
+		a.Name = "deploy keys"
+	default:
+		a.Name = fmt.Sprintf("%s #%d", a.ActorType, id)
+	}
+}
+
+// Rule is a single rule of a ruleset. Parameters hold the decoded JSON
+// parameters, so numbers are float64.
+type Rule struct {
+	Type       string                 `json:"type"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// String lists the parameters of the rule, or enabled for a rule without
+// parameters
+func (r Rule) String() string {
+	if len(r.Parameters) == 0 {
+		return "enabled"
+	}
+	var keys []string
+	for k := range r.Parameters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		v := r.Parameters[k]
+		if checks, ok := v.([]interface{}); ok && k == "required_status_checks" {
+			var contexts []string
+			for _, c := range checks {
+				if m, ok := c.(map[string]interface{}); ok {
+					contexts = append(contexts, fmt.Sprint(m["context"]))
+				}
+			}
+			v = "[" + strings.Join(contexts, ", ") + "]"
+		}
+		parts[i] = fmt.Sprintf("%s: %v", k, v)
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// ListRulesets returns the rulesets of a repository, leaving out those
+// inherited from the organisation
+func ListRulesets(client *Client, org string, reponame string) ([]Ruleset, error) {
+
+	path := fmt.Sprintf("repos/%s/%s/rulesets?includes_parents=false&per_page=100", org, reponame)
+	result := []Ruleset{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return result, err
+}
+
+// GetRuleset returns a ruleset with its conditions, bypass actors and rules
+func GetRuleset(client *Client, org string, reponame string, id string) (*Ruleset, error) {
+
+	path := fmt.Sprintf("repos/%s/%s/rulesets/%s", org, reponame, id)
+	result := Ruleset{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return &result, err
+}
+
+// RulesetAddToRepo creates a ruleset from the given fields
+func RulesetAddToRepo(client *Client, org string, reponame string, name string, fields []FieldDiff) error {
+
+	path := fmt.Sprintf("repos/%s/%s/rulesets", org, reponame)
+	result := Ruleset{}
+
+	j, _ := json.Marshal(rulesetBody(&Ruleset{Name: name}, fields))
+
+	return client.REST("POST", path, bytes.NewBuffer(j), &result)
+}
+
+// RulesetUpdate changes the given fields of a ruleset. The rules are sent as
+// a whole, so the live ruleset is read back and the changed rules replaced.
+func RulesetUpdate(client *Client, org string, reponame string, id string, fields []FieldDiff) error {
+
+	live, err := GetRuleset(client, org, reponame, id)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("repos/%s/%s/rulesets/%s", org, reponame, id)
+	result := Ruleset{}
+
+	j, _ := json.Marshal(rulesetBody(live, fields))
+
+	return client.REST("PUT", path, bytes.NewBuffer(j), &result)
+}
+
+func RulesetDeleteFromRepo(client *Client, org string, reponame string, id string) error {
+
+	path := fmt.Sprintf("repos/%s/%s/rulesets/%s", org, reponame, id)
+	result := Ruleset{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
+
+// rulesetBody returns the request body of live with fields applied on top.
+// A rules.<type> field adds or replaces the rule of that type, or removes
+// it when the new value is nil.
+func rulesetBody(live *Ruleset, fields []FieldDiff) map[string]interface{} {
+	rs := *live
+	rs.Rules = append([]Rule{}, live.Rules...)
+	for _, f := range fields {
+		switch f.Name {
+		case "target":
+			rs.Target = f.New.(string)
+		case "enforcement":
+			rs.Enforcement = f.New.(string)
+		case "include":
+			rs.Conditions.RefName.Include = f.New.([]string)
+		case "exclude":
+			rs.Conditions.RefName.Exclude = f.New.([]string)
+		case "bypass_actors":
+			rs.BypassActors = f.New.([]BypassActor)
+		default:
+			kind := strings.TrimPrefix(f.Name, "rules.")
+			rules := []Rule{}
+			for _, r := range rs.Rules {
+				if r.Type != kind {
+					rules = append(rules, r)
+				}
+			}
+			if r, ok := f.New.(Rule); ok {
+				rules = append(rules, r)
+			}
+			rs.Rules = rules
+		}
+	}
+
+	conditions := map[string]interface{}{"ref_name": map[string][]string{
+		"include": nonNil(rs.Conditions.RefName.Include),
+		"exclude": nonNil(rs.Conditions.RefName.Exclude),
+	}}
+	body := map[string]interface{}{
+		"name":        rs.Name,
+		"conditions":  conditions,
+		"rules":       rs.Rules,
+		"enforcement": rs.Enforcement,
+	}
+	if rs.Target != "" {
+		body["target"] = rs.Target
+	}
+	if rs.BypassActors != nil {
+		body["bypass_actors"] = rs.BypassActors
+	}
+	return body
+}
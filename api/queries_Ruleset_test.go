@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRulesetBody(t *testing.T) {
+	var live Ruleset
+	decodeLive(t, `{"id":10,"name":"main","target":"branch","enforcement":"active",
+		"bypass_actors":[{"actor_id":5,"actor_type":"RepositoryRole","bypass_mode":"always"}],
+		"conditions":{"ref_name":{"include":["~DEFAULT_BRANCH"],"exclude":[]}},
+		"rules":[{"type":"deletion"},{"type":"merge_queue","parameters":{"merge_method":"SQUASH"}},
+			{"type":"pull_request","parameters":{"required_approving_review_count":1}}]}`, &live)
+
+	tests := []struct {
+		name   string
+		fields []FieldDiff
+		want   string
+	}{
+		{
+			name:   "unchanged",
+			fields: nil,
+			want: `{"bypass_actors":[{"actor_id":5,"actor_type":"RepositoryRole","bypass_mode":"always"}],
+				"conditions":{"ref_name":{"exclude":[],"include":["~DEFAULT_BRANCH"]}},"enforcement":"active","name":"main",
+				"rules":[{"type":"deletion"},{"type":"merge_queue","parameters":{"merge_method":"SQUASH"}},
+					{"type":"pull_request","parameters":{"required_approving_review_count":1}}],"target":"branch"}`,
+		},
+		{
+			name: "rules replaced and removed, unmodelled rules kept",
+			fields: []FieldDiff{
+				{"enforcement", "active", "evaluate"},
+				{"include", []string{"~DEFAULT_BRANCH"}, []string{"refs/heads/release/*"}},
+				{"bypass_actors", nil, []BypassActor{}},
+				{"rules.pull_request", nil, Rule{Type: "pull_request", Parameters: map[string]interface{}{"required_approving_review_count": 2}}},
+				{"rules.deletion", Rule{Type: "deletion"}, nil},
+				{"rules.non_fast_forward", nil, Rule{Type: "non_fast_forward"}},
+			},
+			want: `{"bypass_actors":[],
+				"conditions":{"ref_name":{"exclude":[],"include":["refs/heads/release/*"]}},"enforcement":"evaluate","name":"main",
+				"rules":[{"type":"merge_queue","parameters":{"merge_method":"SQUASH"}},
+					{"type":"pull_request","parameters":{"required_approving_review_count":2}},{"type":"non_fast_forward"}],"target":"branch"}`,
+		},
+	}
+	for _, tt := range tests {
+		got, _ := json.Marshal(rulesetBody(&live, tt.fields))
+		var g, w interface{}
+		json.Unmarshal(got, &g)
+		if err := json.Unmarshal([]byte(tt.want), &w); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("%s: body %s, want %s", tt.name, got, strings.Join(strings.Fields(tt.want), ""))
+		}
+	}
+	if len(live.Rules) != 3 {
+		t.Errorf("rulesetBody changed the live rules to %v", live.Rules)
+	}
+}
+
+func TestRefPatterns(t *testing.T) {
+	tests := []struct {
+		target   string
+		patterns []string
+		want     []string
+	}{
+		{"branch", nil, nil},
+		{"branch", []string{}, []string{}},
+		{"branch", []string{"main", "release/*", "~DEFAULT_BRANCH", "refs/heads/dev"}, []string{"refs/heads/main", "refs/heads/release/*", "~DEFAULT_BRANCH", "refs/heads/dev"}},
+		{"tag", []string{"v*", "~ALL"}, []string{"refs/tags/v*", "~ALL"}},
+	}
+	for _, tt := range tests {
+		if got := refPatterns(tt.target, tt.patterns); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("refPatterns(%s, %v) = %v, want %v", tt.target, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestPlanRulesets(t *testing.T) {
+	client, _ := newFakeClient(map[string]string{
+		"GET /orgs/o/teams": `[{"id":21,"slug":"platform","name":"Platform"}]`,
+		"GET /repos/o/r/rulesets/10": `{"id":10,"name":"main","target":"branch","enforcement":"active",
+			"bypass_actors":[{"actor_id":5,"actor_type":"RepositoryRole","bypass_mode":"always"}],
+			"conditions":{"ref_name":{"include":["~DEFAULT_BRANCH"],"exclude":[]}},
+			"rules":[{"type":"deletion"},{"type":"merge_queue","parameters":{"merge_method":"SQUASH"}},
+				{"type":"pull_request","parameters":{"required_approving_review_count":1,"dismiss_stale_reviews_on_push":true,
+					"require_code_owner_review":false,"require_last_push_approval":false,"required_review_thread_resolution":false}}]}`,
+	})
+	live := []Ruleset{{ID: 10, Name: "main", Target: "branch", Enforcement: "active"}, {ID: 12, Name: "old", Target: "tag", Enforcement: "disabled"}}
+	cfg := readConfig(t, `
+rulesets:
+  - name: Main
+    include: [~DEFAULT_BRANCH]
+    bypass_actors:
+      - {role: admin}
+      - {team: Platform, mode: pull_request}
+    rules:
+      pull_request: {required_approving_review_count: 2}
+      deletion: true
+  - name: tags
+    target: tag
+    include: [v*]
+    rules: {deletion: true}
+`)
+	tests := []struct {
+		enforce bool
+		want    string
+	}{
+		{false, "~ ruleset main bypass_actors: [role admin (always)] -> [role admin (always) team platform (pull_request)]" +
+			" rules.pull_request: {dismiss_stale_reviews_on_push: true, require_code_owner_review: false, require_last_push_approval: false, required_approving_review_count: 1, required_review_thread_resolution: false}" +
+			" -> {dismiss_stale_reviews_on_push: true, require_code_owner_review: false, require_last_push_approval: false, required_approving_review_count: 2, required_review_thread_resolution: false}\n" +
+			"+ ruleset tags target: <nil> -> tag enforcement: <nil> -> active include: <nil> -> [refs/tags/v*] rules.deletion: <nil> -> enabled"},
+		{true, "~ ruleset main bypass_actors: [role admin (always)] -> [role admin (always) team platform (pull_request)]" +
+			" rules.pull_request: {dismiss_stale_reviews_on_push: true, require_code_owner_review: false, require_last_push_approval: false, required_approving_review_count: 1, required_review_thread_resolution: false}" +
+			" -> {dismiss_stale_reviews_on_push: true, require_code_owner_review: false, require_last_push_approval: false, required_approving_review_count: 2, required_review_thread_resolution: false}\n" +
+			"+ ruleset tags target: <nil> -> tag enforcement: <nil> -> active include: <nil> -> [refs/tags/v*] rules.deletion: <nil> -> enabled\n" +
+			"- ruleset old target: tag -> <nil> enforcement: disabled -> <nil>"},
+	}
+	for _, tt := range tests {
+		plan := &Plan{Org: "o", Repository: "r"}
+		if err := planRulesets(client, plan, cfg, live, tt.enforce); err != nil {
+			t.Fatal(err)
+		}
+		if got := changeLines(plan); got != tt.want {
+			t.Errorf("enforce %v: planned\n%s\nwant\n%s", tt.enforce, got, tt.want)
+		}
+	}
+
+	cfg = readConfig(t, "rulesets: [{name: main, bypass_actors: [{team: security}]}]")
+	if err := planRulesets(client, &Plan{Org: "o", Repository: "r"}, cfg, live, false); err == nil {
+		t.Error("planned a ruleset bypassed by a team that does not exist")
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// SecretInfo describes a secret. GitHub never returns the value.
+type SecretInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SecretPublicKey is the key secrets must be encrypted with before upload
+type SecretPublicKey struct {
+	KeyID string `json:"key_id"`
+	Key   string `json:"key"`
+}
+
+func secretsPath(org string, reponame string) string {
+	return fmt.Sprintf("repos/%s/%s/actions/secrets", org, reponame)
+}
+
+// ListSecrets returns the Actions secrets of a repository
+func ListSecrets(client *Client, org string, reponame string) ([]SecretInfo, error) {
+	return listSecrets(client, secretsPath(org, reponame))
+}
+
+// GetSecretPublicKey returns the key the Actions secrets of a repository are
+// sealed with
+func GetSecretPublicKey(client *Client, org string, reponame string) (*SecretPublicKey, error) {
+	return getPublicKey(client, secretsPath(org, reponame))
+}
+
+// GetSecret returns a single Actions secret of a repository
+func GetSecret(client *Client, org string, reponame string, name string) (*SecretInfo, error) {
+
+	path := fmt.Sprintf("%s/%s", secretsPath(org, reponame), name)
+	result := SecretInfo{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return &result, err
+}
+
+// SecretUpdate encrypts value with key, the public key of the repository,
+// and creates or replaces the secret
+func SecretUpdate(client *Client, org string, reponame string, name string, value Secret, key *SecretPublicKey) error {
+	return putSecret(client, secretsPath(org, reponame), name, value, key)
+}
+
+func SecretDeleteFromRepo(client *Client, org string, reponame string, name string) error {
+
+	path := fmt.Sprintf("%s/%s", secretsPath(org, reponame), name)
+	result := SecretInfo{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
+
+// listSecrets pages through the secrets under path, which GitHub returns
+// wrapped in an object
+func listSecrets(client *Client, path string) ([]SecretInfo, error) {
+	var secrets []SecretInfo
+	for page := 1; ; page++ {
+		var result struct {
+			TotalCount int          `json:"total_count"`
+			Secrets    []SecretInfo `json:"secrets"`
+		}
+		p := fmt.Sprintf("%s?per_page=100&page=%d", path, page)
+		if err := client.REST("GET", p, &bytes.Buffer{}, &result); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, result.Secrets...)
+		if len(result.Secrets) == 0 || len(secrets) >= result.TotalCount {
+			return secrets, nil
+		}
+	}
+}
+
+// getPublicKey returns the public key of the secrets under path
+func getPublicKey(client *Client, path string) (*SecretPublicKey, error) {
+
+	result := SecretPublicKey{}
+
+	err := client.REST("GET", path+"/public-key", &bytes.Buffer{}, &result)
+	return &result, err
+}
+
+// putSecret seals value with key, the public key of the secrets under path,
+// and uploads the secret
+func putSecret(client *Client, path string, name string, value Secret, key *SecretPublicKey) error {
+
+	encrypted, err := sealSecret(value, key.Key)
+	if err != nil {
+		return err
+	}
+
+	j, _ := json.Marshal(map[string]string{
+		"encrypted_value": encrypted,
+		"key_id":          key.KeyID,
+	})
+	result := SecretInfo{}
+	return client.REST("PUT", fmt.Sprintf("%s/%s", path, name), bytes.NewBuffer(j), &result)
+}
+
+// sealSecret encrypts value for the base64 encoded Curve25519 public key
+// with a libsodium compatible sealed box and returns it base64 encoded
+func sealSecret(value Secret, publicKey string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(raw) != 32 {
+		return "", fmt.Errorf("invalid secrets public key '%s'", publicKey)
+	}
+	var key [32]byte
+	copy(key[:], raw)
+
+	sealed, err := box.SealAnonymous(nil, []byte(value), &key, rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+func TestSealSecret(t *testing.T) {
+	public, private, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := sealSecret("s3cret", base64.StdEncoding.EncodeToString(public[:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, ok := box.OpenAnonymous(nil, raw, public, private)
+	if !ok || string(opened) != "s3cret" {
+		t.Errorf("sealed secret opens to %q, %v", opened, ok)
+	}
+
+	for _, key := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := sealSecret("s3cret", key); err == nil {
+			t.Errorf("sealed with invalid public key %q", key)
+		}
+	}
+}
+
+// TestApplySecrets checks that secrets are sealed with the public key of
+// the repository or environment, which is read once for each
+func TestApplySecrets(t *testing.T) {
+	public, private, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(public[:])
+	client, fake := newFakeClient(map[string]string{
+		"GET /repos/o/r/actions/secrets/public-key":                 `{"key_id":"K1","key":"` + key + `"}`,
+		"GET /repos/o/r/environments/production/secrets/public-key": `{"key_id":"K2","key":"` + key + `"}`,
+	})
+	secret := func(resource, parent, name, value string) Change {
+		return Change{Action: ActionAdd, Resource: resource, Parent: parent, Name: name, Fields: []FieldDiff{{"value", nil, Secret(value)}}}
+	}
+	plan := &Plan{Org: "o", Repository: "r", Changes: []Change{
+		secret("secret", "", "NPM_TOKEN", "s3cret"),
+		secret("environment_secret", "production", "DEPLOY_KEY", "d3ploy"),
+		secret("secret", "", "PYPI_TOKEN", "pypi"),
+		secret("environment_secret", "production", "SIGNING_KEY", "s1gn"),
+	}}
+	if err := ApplyPlan(client, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []string
+	for _, r := range fake.Requests() {
+		parts := strings.SplitN(r, " ", 3)
+		sent = append(sent, parts[0]+" "+parts[1])
+		if parts[0] != "PUT" {
+			continue
+		}
+		var body struct {
+			EncryptedValue string `json:"encrypted_value"`
+			KeyID          string `json:"key_id"`
+		}
+		if err := json.Unmarshal([]byte(parts[2]), &body); err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := base64.StdEncoding.DecodeString(body.EncryptedValue)
+		opened, ok := box.OpenAnonymous(nil, raw, public, private)
+		if !ok || (body.KeyID != "K1" && body.KeyID != "K2") {
+			t.Errorf("uploaded %s, want a value sealed with K1 or K2", parts[2])
+		}
+		sent[len(sent)-1] += " " + string(opened) + " " + body.KeyID
+	}
+	want := `GET /repos/o/r/actions/secrets/public-key
+PUT /repos/o/r/actions/secrets/NPM_TOKEN s3cret K1
+GET /repos/o/r/environments/production/secrets/public-key
+PUT /repos/o/r/environments/production/secrets/DEPLOY_KEY d3ploy K2
+PUT /repos/o/r/actions/secrets/PYPI_TOKEN pypi K1
+PUT /repos/o/r/environments/production/secrets/SIGNING_KEY s1gn K2`
+	if got := strings.Join(sent, "\n"); got != want {
+		t.Errorf("sent\n%s\nwant\n%s", got, want)
+	}
+}
@@ -4,11 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
-
-	"github.com/mkrakowitzer/ghsettings/config"
-	"github.com/mkrakowitzer/ghsettings/utils"
-	"github.com/spf13/cobra"
 )
 
 type Team struct {
@@ -16,79 +11,41 @@ type Team struct {
 	Permission string `json:"permission"`
 }
 
-func UpdateTeam(apiClient *Client, config config.C, cmd *cobra.Command) error {
-	err := TeamAddToRepo(apiClient, config)
-	if err != nil {
-		log.Fatal(err)
-	}
-	enforce, _ := cmd.PersistentFlags().GetBool("enforce")
-	if enforce {
-		err = TeamDeleteFromRepo(apiClient, config)
-	}
-	return err
-}
-
-func TeamAddToRepo(client *Client, config config.C) error {
-
-	for _, s := range config.Teams {
+func TeamAddToRepo(client *Client, org string, reponame string, team string, permission string) error {
 
-		path := fmt.Sprintf("orgs/%s/teams/%s/repos/%s/%s", Org, s.Name, Org, config.Repository.Name)
-		result := Team{}
+	path := fmt.Sprintf("orgs/%s/teams/%s/repos/%s/%s", org, team, org, reponame)
+	result := Team{}
 
-		team := Team{
-			Permission: s.Permission,
-		}
+	body := Team{
+		Permission: permission,
+	}
 
-		j, _ := json.Marshal(team)
+	j, _ := json.Marshal(body)
 
-		err := client.REST("PUT", path, bytes.NewBuffer(j), &result)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return client.REST("PUT", path, bytes.NewBuffer(j), &result)
 }
 
 type Teams []struct {
-	ID     int    `json:"id"`
-	NodeID string `json:"node_id"`
-	Name   string `json:"name"`
-	Slug   string `json:"slug"`
+	ID         int    `json:"id"`
+	NodeID     string `json:"node_id"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	Permission string `json:"permission"`
 }
 
-// Refactor this, was in a hurry
-func TeamDeleteFromRepo(client *Client, config config.C) error {
+func ListTeams(client *Client, org string, reponame string) (Teams, error) {
 
-	path := fmt.Sprintf("repos/%s/%s/teams", Org, config.Repository.Name)
+	path := fmt.Sprintf("repos/%s/%s/teams?per_page=100", org, reponame)
 	result := Teams{}
 
 	err := client.REST("GET", path, &bytes.Buffer{}, &result)
-	if err != nil {
-		return err
-	}
+	return result, err
+}
 
-	var gh_rules []string
-	var yml_rules []string
-	for _, k := range config.Teams {
-		yml_rules = append(yml_rules, k.Name)
-	}
-	for _, k := range result {
-		gh_rules = append(gh_rules, k.Name)
-	}
+func TeamDeleteFromRepo(client *Client, org string, reponame string, slug string) error {
 
-	delete := utils.Missing(yml_rules, gh_rules)
+	result := Teams{}
+	path := fmt.Sprintf("orgs/%s/teams/%s/repos/%s/%s", org, slug, org, reponame)
 
-	for _, k := range result {
-		for _, s := range delete {
-			if k.Name == s {
-				result1 := Teams{}
-				path := fmt.Sprintf("orgs/%s/teams/%s/repos/%s/%s", Org, k.Slug, Org, config.Repository.Name)
-				err := client.REST("DELETE", path, &bytes.Buffer{}, &result1)
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}
-	return nil
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
 }
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ReplaceTopics sets the topics of a repository, removing any not in names
+func ReplaceTopics(client *Client, org string, reponame string, names []string) error {
+
+	path := fmt.Sprintf("repos/%s/%s/topics", org, reponame)
+	j, _ := json.Marshal(map[string][]string{"names": names})
+
+	var result struct {
+		Names []string `json:"names"`
+	}
+	return client.REST("PUT", path, bytes.NewBuffer(j), &result)
+}
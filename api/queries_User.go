@@ -0,0 +1,50 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+type User struct {
+	Login string `json:"login"`
+	ID    int    `json:"id"`
+	Type  string `json:"type"`
+}
+
+// GetScopes returns the OAuth scopes of the token. ok is false when GitHub
+// does not report scopes, as for GitHub App and fine-grained tokens.
+func GetScopes(client *Client) (scopes []string, ok bool, err error) {
+
+	header, err := client.Header("")
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := header["X-Oauth-Scopes"]; !ok {
+		return nil, false, nil
+	}
+	for _, s := range strings.Split(header.Get("X-OAuth-Scopes"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, true, nil
+}
+
+// GetViewer returns the user the token belongs to
+func GetViewer(client *Client) (*User, error) {
+
+	result := User{}
+
+	err := client.REST("GET", "user", &bytes.Buffer{}, &result)
+	return &result, err
+}
+
+func GetUser(client *Client, login string) (*User, error) {
+
+	path := fmt.Sprintf("users/%s", login)
+	result := User{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return &result, err
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Variable is a GitHub Actions configuration variable. Visibility is only
+// set for organisation variables.
+type Variable struct {
+	Name       string    `json:"name"`
+	Value      string    `json:"value"`
+	Visibility string    `json:"visibility"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ListVariables returns the Actions variables of a repository
+func ListVariables(client *Client, org string, reponame string) ([]Variable, error) {
+	return listVariables(client, fmt.Sprintf("repos/%s/%s/actions/variables", org, reponame))
+}
+
+// VariableAddToRepo creates an Actions variable in a repository
+func VariableAddToRepo(client *Client, org string, reponame string, name string, value string) error {
+	return createVariable(client, fmt.Sprintf("repos/%s/%s/actions/variables", org, reponame), name, value)
+}
+
+// VariableUpdate changes the value of an Actions variable in a repository
+func VariableUpdate(client *Client, org string, reponame string, name string, value string) error {
+	return updateVariable(client, fmt.Sprintf("repos/%s/%s/actions/variables", org, reponame), name, value)
+}
+
+func VariableDeleteFromRepo(client *Client, org string, reponame string, name string) error {
+
+	path := fmt.Sprintf("repos/%s/%s/actions/variables/%s", org, reponame, name)
+	result := Variable{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
+
+// ListOrgVariables returns the Actions variables of an organisation
+func ListOrgVariables(client *Client, org string) ([]Variable, error) {
+	return listVariables(client, fmt.Sprintf("orgs/%s/actions/variables", org))
+}
+
+// ListOrgVariableRepositories returns the names of the repositories that can
+// use an organisation variable with selected visibility
+func ListOrgVariableRepositories(client *Client, org string, name string) ([]string, error) {
+	names := []string{}
+	for page := 1; ; page++ {
+		var result struct {
+			TotalCount   int `json:"total_count"`
+			Repositories []struct {
+				Name string `json:"name"`
+			} `json:"repositories"`
+		}
+		p := fmt.Sprintf("orgs/%s/actions/variables/%s/repositories?per_page=100&page=%d", org, name, page)
+		if err := client.REST("GET", p, &bytes.Buffer{}, &result); err != nil {
+			return nil, err
+		}
+		for _, r := range result.Repositories {
+			names = append(names, r.Name)
+		}
+		if len(result.Repositories) == 0 || len(names) >= result.TotalCount {
+			return names, nil
+		}
+	}
+}
+
+// OrgVariableAdd creates an organisation variable with the given fields,
+// value, visibility and repositories
+func OrgVariableAdd(client *Client, org string, name string, fields map[string]interface{}) error {
+
+	path := fmt.Sprintf("orgs/%s/actions/variables", org)
+	result := Variable{}
+
+	body, err := orgVariableBody(client, org, fields)
+	if err != nil {
+		return err
+	}
+	body["name"] = name
+	j, _ := json.Marshal(body)
+
+	return client.REST("POST", path, bytes.NewBuffer(j), &result)
+}
+
+// OrgVariableUpdate changes the given fields of an organisation variable
+func OrgVariableUpdate(client *Client, org string, name string, fields map[string]interface{}) error {
+
+	path := fmt.Sprintf("orgs/%s/actions/variables/%s", org, name)
+	result := Variable{}
+
+	body, err := orgVariableBody(client, org, fields)
+	if err != nil {
+		return err
+	}
+	j, _ := json.Marshal(body)
+
+	return client.REST("PATCH", path, bytes.NewBuffer(j), &result)
+}
+
+func OrgVariableDelete(client *Client, org string, name string) error {
+
+	path := fmt.Sprintf("orgs/%s/actions/variables/%s", org, name)
+	result := Variable{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
+
+// orgVariableBody copies fields into a request body, replacing the names of
+// the selected repositories with the IDs the API expects
+func orgVariableBody(client *Client, org string, fields map[string]interface{}) (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+	for k, v := range fields {
+		if k != "repositories" {
+			body[k] = v
+			continue
+		}
+		repos, err := ListRepositories(client, org)
+		if err != nil {
+			return nil, err
+		}
+		ids := []int64{}
+		for _, name := range v.([]string) {
+			id := int64(0)
+			for _, r := range repos {
+				if strings.EqualFold(r.Name, name) {
+					id = r.ID
+				}
+			}
+			if id == 0 {
+				return nil, fmt.Errorf("repository %s not found in %s", name, org)
+			}
+			ids = append(ids, id)
+		}
+		body["selected_repository_ids"] = ids
+	}
+	return body, nil
+}
+
+// createVariable creates a variable under path
+func createVariable(client *Client, path string, name string, value string) error {
+
+	result := Variable{}
+
+	j, _ := json.Marshal(map[string]string{"name": name, "value": value})
+
+	return client.REST("POST", path, bytes.NewBuffer(j), &result)
+}
+
+// updateVariable changes the value of the variable name under path
+func updateVariable(client *Client, path string, name string, value string) error {
+
+	result := Variable{}
+
+	j, _ := json.Marshal(map[string]string{"name": name, "value": value})
+
+	return client.REST("PATCH", fmt.Sprintf("%s/%s", path, name), bytes.NewBuffer(j), &result)
+}
+
+// listVariables pages through the variables under path, which GitHub returns
+// wrapped in an object. At most 30 variables are returned per page.
+func listVariables(client *Client, path string) ([]Variable, error) {
+	var variables []Variable
+	for page := 1; ; page++ {
+		var result struct {
+			TotalCount int        `json:"total_count"`
+			Variables  []Variable `json:"variables"`
+		}
+		p := fmt.Sprintf("%s?per_page=30&page=%d", path, page)
+		if err := client.REST("GET", p, &bytes.Buffer{}, &result); err != nil {
+			return nil, err
+		}
+		variables = append(variables, result.Variables...)
+		if len(result.Variables) == 0 || len(variables) >= result.TotalCount {
+			return variables, nil
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type Webhook struct {
+	ID        int64         `json:"id"`
+	Active    bool          `json:"active"`
+	Events    []string      `json:"events"`
+	Config    WebhookConfig `json:"config"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// WebhookConfig holds the delivery settings of a webhook. GitHub returns
+// ******** as the secret when one is set.
+type WebhookConfig struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	InsecureSSL string `json:"insecure_ssl"`
+	Secret      string `json:"secret,omitempty"`
+}
+
+type Webhooks []Webhook
+
+// ListWebhooks returns every webhook of a repository
+func ListWebhooks(client *Client, org string, reponame string) (Webhooks, error) {
+
+	path := fmt.Sprintf("repos/%s/%s/hooks?per_page=100", org, reponame)
+	result := Webhooks{}
+
+	err := client.REST("GET", path, &bytes.Buffer{}, &result)
+	return result, err
+}
+
+// webhookHookFields are set on the webhook itself, all other fields are part
+// of its config
+var webhookHookFields = map[string]bool{"active": true, "events": true}
+
+// splitWebhookFields separates the fields of a change into those of the hook
+// and those of its config, in the form the REST API expects
+func splitWebhookFields(fields []FieldDiff) (map[string]interface{}, map[string]interface{}) {
+	hook := map[string]interface{}{}
+	config := map[string]interface{}{}
+	for _, f := range fields {
+		switch v := f.New.(type) {
+		case Secret:
+			config[f.Name] = string(v)
+		case bool:
+			if webhookHookFields[f.Name] {
+				hook[f.Name] = v
+			} else if v {
+				config[f.Name] = "1"
+			} else {
+				config[f.Name] = "0"
+			}
+		default:
+			if webhookHookFields[f.Name] {
+				hook[f.Name] = v
+			} else {
+				config[f.Name] = v
+			}
+		}
+	}
+	return hook, config
+}
+
+// WebhookAddToRepo creates a webhook delivering to url with the given fields
+func WebhookAddToRepo(client *Client, org string, reponame string, url string, fields []FieldDiff) error {
+
+	path := fmt.Sprintf("repos/%s/%s/hooks", org, reponame)
+	result := Webhook{}
+
+	hook, config := splitWebhookFields(fields)
+	config["url"] = url
+	hook["name"] = "web"
+	hook["config"] = config
+
+	j, _ := json.Marshal(hook)
+
+	return client.REST("POST", path, bytes.NewBuffer(j), &result)
+}
+
+// WebhookUpdate changes the given fields of a webhook. Config fields are sent
+// to the config endpoint so the settings not given, such as the secret, are
+// kept.
+func WebhookUpdate(client *Client, org string, reponame string, id string, fields []FieldDiff) error {
+
+	hook, config := splitWebhookFields(fields)
+
+	if len(hook) > 0 {
+		path := fmt.Sprintf("repos/%s/%s/hooks/%s", org, reponame, id)
+		result := Webhook{}
+		j, _ := json.Marshal(hook)
+		if err := client.REST("PATCH", path, bytes.NewBuffer(j), &result); err != nil {
+			return err
+		}
+	}
+	if len(config) > 0 {
+		path := fmt.Sprintf("repos/%s/%s/hooks/%s/config", org, reponame, id)
+		result := WebhookConfig{}
+		j, _ := json.Marshal(config)
+		return client.REST("PATCH", path, bytes.NewBuffer(j), &result)
+	}
+	return nil
+}
+
+func WebhookDeleteFromRepo(client *Client, org string, reponame string, id string) error {
+
+	path := fmt.Sprintf("repos/%s/%s/hooks/%s", org, reponame, id)
+	result := Webhook{}
+
+	return client.REST("DELETE", path, &bytes.Buffer{}, &result)
+}
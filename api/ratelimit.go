@@ -0,0 +1,180 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// secondaryRetries is how often a request hitting a secondary rate limit is
+// retried before the error is returned
+const secondaryRetries = 3
+
+// RateLimit turns a RoundTripper into one that tracks the X-RateLimit headers
+// of every response. Once the remaining budget of a resource (core, graphql)
+// falls below floor, requests for it wait until the budget resets. Responses
+// to secondary rate limits with a Retry-After header are retried after the
+// given delay, and requests rejected because the budget ran out are retried
+// after the reset.
+func RateLimit(floor int) ClientOption {
+	return func(tr http.RoundTripper) http.RoundTripper {
+		return &rateLimiter{
+			tr:     tr,
+			floor:  floor,
+			limits: map[string]rateState{},
+		}
+	}
+}
+
+type rateState struct {
+	remaining int
+	reset     time.Time
+}
+
+type rateLimiter struct {
+	tr     http.RoundTripper
+	floor  int
+	mu     sync.Mutex
+	limits map[string]rateState
+}
+
+func (r *rateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := rateResource(req)
+
+	for attempt := 0; ; attempt++ {
+		if err := r.wait(req, resource); err != nil {
+			return nil, err
+		}
+
+		resp, err := r.tr.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		r.update(resource, resp)
+
+		delay, limited := rateLimited(resp)
+		if !limited || attempt >= secondaryRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		requestLogger(req).WithFields(log.Fields{
+			"url":   req.URL.String(),
+			"delay": delay.String(),
+		}).Warn("rate limited, retrying")
+		if err := sleep(req, delay); err != nil {
+			return nil, err
+		}
+
+		req, err = rewind(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait blocks until the budget of the resource is above the floor or has reset
+func (r *rateLimiter) wait(req *http.Request, resource string) error {
+	r.mu.Lock()
+	state, ok := r.limits[resource]
+	r.mu.Unlock()
+
+	if !ok || state.remaining >= r.floor {
+		return nil
+	}
+	delay := time.Until(state.reset) + time.Second
+	if delay > 0 {
+		requestLogger(req).WithFields(log.Fields{
+			"resource":  resource,
+			"remaining": state.remaining,
+			"reset":     state.reset.Format(time.RFC3339),
+		}).Warn("rate limit below floor, waiting for reset")
+		if err := sleep(req, delay); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	if r.limits[resource] == state {
+		delete(r.limits, resource)
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// update records the budget reported by a response
+func (r *rateLimiter) update(resource string, resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	if name := resp.Header.Get("X-RateLimit-Resource"); name != "" {
+		resource = name
+	}
+
+	r.mu.Lock()
+	r.limits[resource] = rateState{remaining: remaining, reset: time.Unix(reset, 0)}
+	r.mu.Unlock()
+}
+
+// rateResource guesses the rate limit resource a request is counted against
+func rateResource(req *http.Request) string {
+	if strings.HasSuffix(req.URL.Path, "/graphql") {
+		return "graphql"
+	}
+	return "core"
+}
+
+// rateLimited reports whether a response was rejected by a rate limit and how
+// long to wait before retrying
+func rateLimited(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(s) * time.Second, true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err == nil {
+			return time.Until(time.Unix(reset, 0)) + time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// sleep waits for the delay or until the request is cancelled
+func sleep(req *http.Request, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// rewind returns a copy of the request with a fresh body so it can be sent again
+func rewind(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
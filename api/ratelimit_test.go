@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimited(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		min     time.Duration
+		max     time.Duration
+		limited bool
+	}{
+		{"success", http.StatusOK, map[string]string{"Retry-After": "5"}, 0, 0, false},
+		{"secondary limit", http.StatusForbidden, map[string]string{"Retry-After": "5"}, 5 * time.Second, 5 * time.Second, true},
+		{"too many requests", http.StatusTooManyRequests, map[string]string{"Retry-After": "1"}, time.Second, time.Second, true},
+		{"budget ran out", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": reset}, 59 * time.Second, 61 * time.Second, true},
+		{"forbidden", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "4000"}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		for k, v := range tt.headers {
+			resp.Header.Set(k, v)
+		}
+		delay, limited := rateLimited(resp)
+		if limited != tt.limited || delay < tt.min || delay > tt.max {
+			t.Errorf("rateLimited(%s) = %s, %v, want between %s and %s, %v", tt.name, delay, limited, tt.min, tt.max, tt.limited)
+		}
+	}
+}
+
+// headerTripper answers with the given responses in turn, repeating the last
+type headerTripper struct {
+	responses []*http.Response
+	requests  int
+}
+
+func (h *headerTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.requests++
+	resp := *h.responses[0]
+	if len(h.responses) > 1 {
+		h.responses = h.responses[1:]
+	}
+	resp.Body = ioutil.NopCloser(strings.NewReader("{}"))
+	resp.Request = req
+	return &resp, nil
+}
+
+func limitResponse(status int, headers ...string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: http.Header{}}
+	for i := 0; i < len(headers); i += 2 {
+		resp.Header.Set(headers[i], headers[i+1])
+	}
+	return resp
+}
+
+func TestRateLimitRetriesSecondaryLimit(t *testing.T) {
+	h := &headerTripper{responses: []*http.Response{
+		limitResponse(http.StatusForbidden, "Retry-After", "0"),
+		limitResponse(http.StatusOK),
+	}}
+	req, _ := http.NewRequest("PUT", "https://api.github.com/repos/o/r/topics", strings.NewReader(`{"names":[]}`))
+	resp, err := RateLimit(10)(h).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || h.requests != 2 {
+		t.Errorf("got %d after %d requests, want 200 after 2", resp.StatusCode, h.requests)
+	}
+}
+
+func TestRateLimitWaitsBelowFloor(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	h := &headerTripper{responses: []*http.Response{
+		limitResponse(http.StatusOK, "X-RateLimit-Remaining", "5", "X-RateLimit-Reset", reset, "X-RateLimit-Resource", "graphql"),
+	}}
+	tr := RateLimit(10)(h)
+	graphql, _ := http.NewRequest("POST", "https://api.github.com/graphql", strings.NewReader("{}"))
+	if _, err := tr.RoundTrip(graphql); err != nil {
+		t.Fatal(err)
+	}
+
+	rest, _ := http.NewRequest("GET", "https://api.github.com/repos/o/r", nil)
+	if _, err := tr.RoundTrip(rest); err != nil {
+		t.Errorf("core request waited for the graphql budget: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	graphql, _ = http.NewRequest("POST", "https://api.github.com/graphql", strings.NewReader("{}"))
+	if _, err := tr.RoundTrip(graphql.WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Errorf("graphql request below the floor returned %v, want it to wait for the reset", err)
+	}
+	if h.requests != 2 {
+		t.Errorf("sent %d requests, want 2", h.requests)
+	}
+}
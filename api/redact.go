@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/henvic/httpretty"
+)
+
+// redacted replaces secret values wherever they are printed
+const redacted = "********"
+
+// Secret is a value that is sent to GitHub but never printed. It formats as
+// ******** so it can be kept in a plan.
+type Secret string
+
+func (s Secret) String() string {
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// redactedFields are the JSON keys whose values VerboseLog masks
+var redactedFields = map[string]bool{
+	"secret":          true,
+	"token":           true,
+	"encrypted_value": true,
+}
+
+// redactFormatter pretty prints JSON bodies with the values of redactedFields
+// masked. It never fails, as httpretty prints the raw body when a formatter
+// returns an error.
+type redactFormatter struct {
+	json httpretty.JSONFormatter
+}
+
+func (f *redactFormatter) Match(mediatype string) bool {
+	return f.json.Match(mediatype)
+}
+
+func (f *redactFormatter) Format(w io.Writer, src []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(src, &v); err != nil {
+		_, err = io.WriteString(w, "* body is not valid JSON and is not shown")
+		return err
+	}
+	b, _ := json.Marshal(redact(v))
+	if err := f.json.Format(w, b); err != nil {
+		_, err = w.Write(b)
+		return err
+	}
+	return nil
+}
+
+// redact masks the values of redactedFields in a decoded JSON value
+func redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if redactedFields[k] {
+				t[k] = redacted
+				continue
+			}
+			t[k] = redact(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = redact(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRedactFormatter(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		hidden []string
+		shown  []string
+	}{
+		{
+			name:   "webhook",
+			body:   `{"config":{"url":"https://ci.example.com/hook","secret":"s3cret"},"events":["push"]}`,
+			hidden: []string{"s3cret"},
+			shown:  []string{"https://ci.example.com/hook", `"secret": "********"`},
+		},
+		{
+			name:   "secrets in a list",
+			body:   `[{"token":"ghs_abc"},{"encrypted_value":"c2VhbGVk","key_id":"K1"}]`,
+			hidden: []string{"ghs_abc", "c2VhbGVk"},
+			shown:  []string{"K1"},
+		},
+		{
+			name:   "not JSON",
+			body:   `secret=s3cret`,
+			hidden: []string{"s3cret"},
+			shown:  []string{"not valid JSON"},
+		},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := (&redactFormatter{}).Format(&b, []byte(tt.body)); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+		}
+		for _, s := range tt.hidden {
+			if strings.Contains(b.String(), s) {
+				t.Errorf("%s: printed %s in\n%s", tt.name, s, b.String())
+			}
+		}
+		for _, s := range tt.shown {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("%s: did not print %s in\n%s", tt.name, s, b.String())
+			}
+		}
+	}
+}
+
+func TestSecretFormat(t *testing.T) {
+	s := Secret("s3cret")
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		if got := fmt.Sprintf(format, []FieldDiff{{Name: "secret", New: s}}); strings.Contains(got, "s3cret") {
+			t.Errorf("%s prints the secret: %s", format, got)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// maxBackoff caps the delay between two attempts
+const maxBackoff = 30 * time.Second
+
+// Retry turns a RoundTripper into one that retries requests failing with a
+// network error, a 502, 503 or 504 status or a GraphQL "something went wrong"
+// error. Up to attempts requests are made, waiting a jittered exponential
+// backoff starting at base in between. Only requests that are safe to repeat
+// are retried: REST requests other than POST and DELETE, GraphQL queries and
+// the GraphQL mutations listed in idempotentMutations. A deletion that
+// succeeded behind a failed response would fail again as not found, so
+// deletions are never retried.
+func Retry(attempts int, base time.Duration) ClientOption {
+	return func(tr http.RoundTripper) http.RoundTripper {
+		return &funcTripper{roundTrip: func(req *http.Request) (*http.Response, error) {
+			retryable := retryableRequest(req)
+			for attempt := 1; ; attempt++ {
+				resp, err := tr.RoundTrip(req)
+				if !retryable || attempt >= attempts || req.Context().Err() != nil || !transient(resp, err) {
+					return resp, err
+				}
+				if resp != nil {
+					resp.Body.Close()
+				}
+
+				delay := backoff(base, attempt)
+				fields := log.Fields{
+					"url":     req.URL.String(),
+					"attempt": attempt,
+					"delay":   delay.String(),
+				}
+				if err != nil {
+					fields["error"] = err.Error()
+				} else {
+					fields["status"] = resp.StatusCode
+				}
+				requestLogger(req).WithFields(fields).Warn("transient error, retrying")
+
+				if err := sleep(req, delay); err != nil {
+					return nil, err
+				}
+				req, err = rewind(req)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}}
+	}
+}
+
+// backoff returns a random delay between half and all of base*2^(attempt-1)
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base << uint(attempt-1)
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryableRequest reports whether sending the request twice is harmless
+func retryableRequest(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "DELETE":
+		return false
+	case "POST":
+	default:
+		return true
+	}
+	if !strings.HasSuffix(req.URL.Path, "/graphql") || req.GetBody == nil {
+		return false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+	var payload struct {
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(body).Decode(&payload); err != nil {
+		return false
+	}
+	query := strings.TrimSpace(payload.Query)
+	if !strings.HasPrefix(query, "mutation") {
+		return true
+	}
+	fields := mutationFields(query)
+	for _, f := range fields {
+		if !idempotentMutations[f] {
+			return false
+		}
+	}
+	return len(fields) > 0
+}
+
+// idempotentMutations are the GraphQL mutations that leave the same state
+// behind, and succeed, when they are sent twice
+var idempotentMutations = map[string]bool{
+	"updateRepository":           true,
+	"updateBranchProtectionRule": true,
+}
+
+// mutationFields returns the names of the top level fields of a GraphQL
+// operation, which name the mutations it runs. Aliases, arguments and string
+// literals are skipped.
+func mutationFields(query string) []string {
+	var fields []string
+	depth, parens := 0, 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '"':
+			for i++; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+		case c == '(':
+			parens++
+		case c == ')':
+			parens--
+		case c == '{' && parens == 0:
+			depth++
+		case c == '}' && parens == 0:
+			depth--
+		case depth == 1 && parens == 0 && (c == '_' || unicode.IsLetter(rune(c))):
+			j := i
+			for j < len(query) && (query[j] == '_' || unicode.IsLetter(rune(query[j])) || unicode.IsDigit(rune(query[j]))) {
+				j++
+			}
+			name := query[i:j]
+			if k := strings.TrimLeftFunc(query[j:], unicode.IsSpace); !strings.HasPrefix(k, ":") {
+				fields = append(fields, name)
+			}
+			i = j - 1
+		}
+	}
+	return fields
+}
+
+// transient reports whether a failed request is worth retrying. The body of
+// a GraphQL response is read to look for errors and put back for the caller.
+func transient(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusOK:
+	default:
+		return false
+	}
+	if !strings.HasSuffix(resp.Request.URL.Path, "/graphql") {
+		return false
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return true
+	}
+	var gr struct {
+		Errors []GraphQLError
+	}
+	if json.Unmarshal(b, &gr) != nil {
+		return false
+	}
+	for _, e := range gr.Errors {
+		if strings.Contains(strings.ToLower(e.Message), "something went wrong") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{4, 400 * time.Millisecond, 800 * time.Millisecond},
+		{10, maxBackoff / 2, maxBackoff},
+		{80, maxBackoff / 2, maxBackoff},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if d := backoff(100*time.Millisecond, tt.attempt); d < tt.min || d > tt.max {
+				t.Errorf("backoff of attempt %d is %s, want between %s and %s", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func graphQLRequest(query string) *http.Request {
+	body, _ := json.Marshal(map[string]string{"query": query})
+	req, _ := http.NewRequest("POST", "https://api.github.com/graphql", bytes.NewReader(body))
+	return req
+}
+
+func TestRetryableRequest(t *testing.T) {
+	get, _ := http.NewRequest("GET", "https://api.github.com/repos/o/r", nil)
+	put, _ := http.NewRequest("PUT", "https://api.github.com/repos/o/r/topics", strings.NewReader(`{"names":[]}`))
+	post, _ := http.NewRequest("POST", "https://api.github.com/repos/o/r/labels", strings.NewReader(`{"name":"bug"}`))
+	del, _ := http.NewRequest("DELETE", "https://api.github.com/repos/o/r/labels/bug", nil)
+	unbuffered, _ := http.NewRequest("PATCH", "https://api.github.com/repos/o/r", ioutil.NopCloser(strings.NewReader("{}")))
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want bool
+	}{
+		{"GET", get, true},
+		{"PUT", put, true},
+		{"REST POST", post, false},
+		{"DELETE", del, false},
+		{"body that can not be sent again", unbuffered, false},
+		{"query", graphQLRequest(`query { viewer { login } }`), true},
+		{"idempotent mutation", graphQLRequest(`mutation { updateRepository(input: {repositoryId: "R1"}) { clientMutationId } }`), true},
+		{"delete mutation", graphQLRequest(`mutation { deleteBranchProtectionRule(input: {branchProtectionRuleId: "B1"}) { clientMutationId } }`), false},
+		{"create mutation", graphQLRequest(`mutation { createBranchProtectionRule(input: {pattern: "main"}) { clientMutationId } }`), false},
+		{"aliased create mutation", graphQLRequest(`mutation { update: createBranchProtectionRule(input: {}) { clientMutationId } }`), false},
+		{"one mutation not idempotent", graphQLRequest(`mutation { updateRepository(input: {}) { clientMutationId } addStar(input: {}) { clientMutationId } }`), false},
+	}
+	for _, tt := range tests {
+		if got := retryableRequest(tt.req); got != tt.want {
+			t.Errorf("retryableRequest(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMutationFields(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`mutation { updateRepository(input: {}) { clientMutationId } }`, []string{"updateRepository"}},
+		{`mutation($id: ID!) { a: deleteBranchProtectionRule(input: {branchProtectionRuleId: $id}) { clientMutationId } }`, []string{"deleteBranchProtectionRule"}},
+		{`mutation { updateRepository(input: {description: "a } createRef {"}) { repository { name } } }`, []string{"updateRepository"}},
+		{`mutation { updateRepository(input: {}) { clientMutationId } updateBranchProtectionRule(input: {}) { clientMutationId } }`, []string{"updateRepository", "updateBranchProtectionRule"}},
+	}
+	for _, tt := range tests {
+		if got := mutationFields(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mutationFields(%s) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func response(url string, status int, body string) *http.Response {
+	req, _ := http.NewRequest("POST", url, nil)
+	return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(body)), Request: req}
+}
+
+func TestTransient(t *testing.T) {
+	const graphql = "https://api.github.com/graphql"
+	const rest = "https://api.github.com/repos/o/r"
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{"network error", nil, errors.New("connection reset by peer"), true},
+		{"502", response(rest, http.StatusBadGateway, ""), nil, true},
+		{"503", response(rest, http.StatusServiceUnavailable, ""), nil, true},
+		{"504", response(rest, http.StatusGatewayTimeout, ""), nil, true},
+		{"500", response(rest, http.StatusInternalServerError, ""), nil, false},
+		{"404", response(rest, http.StatusNotFound, ""), nil, false},
+		{"REST success", response(rest, http.StatusOK, `{"errors":[{"message":"Something went wrong"}]}`), nil, false},
+		{"GraphQL success", response(graphql, http.StatusOK, `{"data":{}}`), nil, false},
+		{"GraphQL error", response(graphql, http.StatusOK, `{"errors":[{"message":"Could not resolve to a Repository"}]}`), nil, false},
+		{"GraphQL something went wrong", response(graphql, http.StatusOK, `{"data":null,"errors":[{"message":"Something went wrong while executing your query."}]}`), nil, true},
+	}
+	for _, tt := range tests {
+		var body string
+		if tt.resp != nil {
+			b, _ := ioutil.ReadAll(tt.resp.Body)
+			body = string(b)
+			tt.resp.Body = ioutil.NopCloser(strings.NewReader(body))
+		}
+		if got := transient(tt.resp, tt.err); got != tt.want {
+			t.Errorf("transient(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.resp != nil {
+			if b, _ := ioutil.ReadAll(tt.resp.Body); string(b) != body {
+				t.Errorf("transient(%s) left the body %q, want %q", tt.name, b, body)
+			}
+		}
+	}
+}
+
+// statusTripper answers with the given statuses in turn, repeating the last,
+// and records the body of every request
+type statusTripper struct {
+	statuses []int
+	bodies   []string
+}
+
+func (s *statusTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+	}
+	s.bodies = append(s.bodies, body)
+	status := s.statuses[0]
+	if len(s.statuses) > 1 {
+		s.statuses = s.statuses[1:]
+	}
+	return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader("{}")), Header: http.Header{}, Request: req}, nil
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		statuses []int
+		requests int
+		status   int
+	}{
+		{"recovers", "PATCH", []int{503, 502, 200}, 3, 200},
+		{"gives up", "PATCH", []int{503}, 3, 503},
+		{"permanent error", "PATCH", []int{404}, 1, 404},
+		{"POST is not retried", "POST", []int{503, 200}, 1, 503},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &statusTripper{statuses: tt.statuses}
+			tr := Retry(3, time.Millisecond)(s)
+			req, _ := http.NewRequest(tt.method, "https://api.github.com/repos/o/r", strings.NewReader(`{"private":true}`))
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status || len(s.bodies) != tt.requests {
+				t.Errorf("got %d after %d requests, want %d after %d", resp.StatusCode, len(s.bodies), tt.status, tt.requests)
+			}
+			for _, b := range s.bodies {
+				if b != `{"private":true}` {
+					t.Errorf("retried with body %q", b)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mkrakowitzer/ghsettings/api"
+	"github.com/mkrakowitzer/ghsettings/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the token, organisation access and config before a run",
+	Long: `Check the token, organisation access and config before a run
+
+Verifies that the token has the repo and admin:org scopes, that its user is an
+owner of GITHUB_ORG, that every user and team referenced in the config files
+exists and that there is rate limit headroom left. Exits non-zero if any check
+fails.`,
+	RunE: runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// requiredScopes are the OAuth scopes ghsettings needs to manage repositories
+var requiredScopes = []string{"repo", "admin:org"}
+
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "FAIL"
+)
+
+type doctor struct {
+	out    io.Writer
+	failed int
+}
+
+func (d *doctor) report(status string, name string, format string, a ...interface{}) {
+	if status == checkFail {
+		d.failed++
+	}
+	fmt.Fprintf(d.out, "[%s] %s: %s\n", status, name, fmt.Sprintf(format, a...))
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+
+	d := &doctor{out: cmd.OutOrStdout()}
+	org := viper.GetString("GITHUB_ORG")
+	if org == "" {
+		d.report(checkFail, "organisation", "GITHUB_ORG is not set, export it with the login of your organisation")
+	}
+
+	apiClient, err := newAPIClient()
+	if err != nil {
+		d.report(checkFail, "token", "%s", err)
+		return doctorResult(cmd, d)
+	}
+
+	isApp := viper.GetInt64("app_id") != 0
+	d.checkScopes(apiClient)
+	if org != "" && !isApp {
+		d.checkMembership(apiClient, org)
+	}
+	if org != "" {
+		d.checkReferences(apiClient, org)
+	}
+	d.checkRateLimit(apiClient)
+
+	return doctorResult(cmd, d)
+}
+
+func doctorResult(cmd *cobra.Command, d *doctor) error {
+	cmd.SilenceUsage = true
+	if d.failed > 0 {
+		return fmt.Errorf("%d checks failed", d.failed)
+	}
+	return nil
+}
+
+func (d *doctor) checkScopes(client *api.Client) {
+	scopes, ok, err := api.GetScopes(client)
+	if err != nil {
+		d.report(checkFail, "token", "the token was rejected: %s", err)
+		return
+	}
+	if !ok {
+		d.report(checkWarn, "token scopes", "GitHub reports no OAuth scopes for this token (GitHub App or fine-grained token), make sure it has administration and members permissions")
+		return
+	}
+
+	var missing []string
+	for _, s := range requiredScopes {
+		if !hasScope(scopes, s) {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) > 0 {
+		d.report(checkFail, "token scopes", "missing %s, the token has [%s]. Create a token with the %s scopes",
+			strings.Join(missing, ", "), strings.Join(scopes, ", "), strings.Join(requiredScopes, " and "))
+		return
+	}
+	d.report(checkOK, "token scopes", "%s", strings.Join(scopes, ", "))
+}
+
+// hasScope reports whether scope is one of scopes
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *doctor) checkMembership(client *api.Client, org string) {
+	viewer, err := api.GetViewer(client)
+	if err != nil {
+		d.report(checkFail, "organisation", "could not look up the token user: %s", err)
+		return
+	}
+
+	membership, err := api.GetOrgMembership(client, org, viewer.Login)
+	if api.IsNotFound(err) {
+		d.report(checkFail, "organisation", "%s is not a member of %s or the organisation does not exist, check GITHUB_ORG", viewer.Login, org)
+		return
+	} else if err != nil {
+		d.report(checkFail, "organisation", "could not look up the membership of %s in %s: %s", viewer.Login, org, err)
+		return
+	}
+	if membership.State != "active" {
+		d.report(checkFail, "organisation", "the invitation of %s to %s is %s, accept it first", viewer.Login, org, membership.State)
+		return
+	}
+	if membership.Role != "admin" {
+		d.report(checkFail, "organisation", "%s is a %s of %s, but must be an owner to manage repository settings", viewer.Login, membership.Role, org)
+		return
+	}
+	d.report(checkOK, "organisation", "%s is an owner of %s", viewer.Login, org)
+}
+
+// checkReferences looks up every user and team named in the config files
+func (d *doctor) checkReferences(client *api.Client, org string) {
+	files, err := configFiles()
+	if err != nil {
+		d.report(checkFail, "config", "%s", err)
+		return
+	}
+	defaults, err := readDefaults()
+	if err != nil {
+		d.report(checkFail, "config", "%s", err)
+		return
+	}
+
+	users := map[string][]string{}
+	teams := map[string][]string{}
+	for _, f := range files {
+		c, err := config.Read(f, defaults)
+		if err != nil {
+			d.report(checkFail, "config", "%s", err)
+			continue
+		}
+		for _, k := range c.Collaborators {
+			users[k.Username] = append(users[k.Username], f)
+		}
+		for _, k := range c.Teams {
+			teams[k.Name] = append(teams[k.Name], f)
+		}
+		for _, env := range c.Environments {
+			if env.Reviewers == nil {
+				continue
+			}
+			for _, u := range env.Reviewers.Users {
+				users[u] = append(users[u], f)
+			}
+			for _, t := range env.Reviewers.Teams {
+				teams[t] = append(teams[t], f)
+			}
+		}
+		for _, r := range c.Rulesets {
+			for _, a := range r.BypassActors {
+				if a.Team != "" {
+					teams[a.Team] = append(teams[a.Team], f)
+				}
+			}
+		}
+	}
+
+	missing := 0
+	for _, u := range sortedKeys(users) {
+		_, err := api.GetUser(client, u)
+		if api.IsNotFound(err) {
+			missing++
+			d.report(checkFail, "users", "user %s does not exist, referenced in %s", u, strings.Join(users[u], ", "))
+		} else if err != nil {
+			missing++
+			d.report(checkFail, "users", "could not look up user %s: %s", u, err)
+		}
+	}
+	if len(teams) > 0 {
+		orgTeams, err := api.ListOrgTeams(client, org)
+		if err != nil {
+			missing++
+			d.report(checkFail, "teams", "could not list the teams of %s: %s", org, err)
+		}
+		for _, t := range sortedKeys(teams) {
+			if err == nil && api.MatchTeam(orgTeams, t) == nil {
+				missing++
+				d.report(checkFail, "teams", "team %s does not exist in %s, referenced in %s", t, org, strings.Join(teams[t], ", "))
+			}
+		}
+	}
+	if missing == 0 {
+		d.report(checkOK, "references", "%d users and %d teams in %d config files exist", len(users), len(teams), len(files))
+	}
+}
+
+func (d *doctor) checkRateLimit(client *api.Client) {
+	rate, err := api.GetRateLimit(client)
+	if err != nil {
+		d.report(checkWarn, "rate limit", "could not read the rate limit: %s", err)
+		return
+	}
+
+	floor := viper.GetInt("rate-limit-floor")
+	core := rate.Resources.Core
+	graphql := rate.Resources.Graphql
+	status := checkOK
+	if core.Remaining <= floor || graphql.Remaining <= floor {
+		status = checkWarn
+	}
+	d.report(status, "rate limit", "core %d/%d, graphql %d/%d remaining",
+		core.Remaining, core.Limit, graphql.Remaining, graphql.Limit)
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,107 @@
+package command
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkrakowitzer/ghsettings/api"
+	"github.com/mkrakowitzer/ghsettings/api/apitest"
+	"github.com/mkrakowitzer/ghsettings/context"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestDoctor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "r.yaml")
+	err = ioutil.WriteFile(file, []byte(`
+repository: {name: r}
+collaborators:
+  - {username: dev, permission: push}
+  - {username: ghost, permission: pull}
+teams:
+  - {name: Platform, permission: push}
+  - {name: security, permission: pull}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("GITHUB_ORG", "o")
+	viper.Set("files", []string{file})
+	defer viper.Set("GITHUB_ORG", "")
+	defer viper.Set("files", nil)
+
+	healthy := map[string]string{
+		"GET /":                      `{}`,
+		"GET /user":                  `{"login":"me"}`,
+		"GET /orgs/o/memberships/me": `{"state":"active","role":"admin"}`,
+		"GET /users/dev":             `{"login":"dev"}`,
+		"GET /users/ghost":           `{"login":"ghost"}`,
+		"GET /orgs/o/teams":          `[{"name":"Platform","slug":"platform"},{"name":"Security","slug":"security"}]`,
+		"GET /rate_limit":            `{"resources":{"core":{"limit":5000,"remaining":4000},"graphql":{"limit":5000,"remaining":4000}}}`,
+	}
+	tests := []struct {
+		name    string
+		scopes  string
+		missing []string
+		teams   string
+		want    string
+	}{
+		{
+			name:   "healthy",
+			scopes: "repo, admin:org",
+			want: "[ok] token scopes: repo, admin:org\n" +
+				"[ok] organisation: me is an owner of o\n" +
+				"[ok] references: 2 users and 2 teams in 1 config files exist\n" +
+				"[ok] rate limit: core 4000/5000, graphql 4000/5000 remaining\n",
+		},
+		{
+			name:    "missing scope, user and team",
+			scopes:  "repo, read:org",
+			missing: []string{"GET /users/ghost"},
+			teams:   `[{"name":"Platform","slug":"platform"}]`,
+			want: "[FAIL] token scopes: missing admin:org, the token has [repo, read:org]. Create a token with the repo and admin:org scopes\n" +
+				"[ok] organisation: me is an owner of o\n" +
+				"[FAIL] users: user ghost does not exist, referenced in " + file + "\n" +
+				"[FAIL] teams: team security does not exist in o, referenced in " + file + "\n" +
+				"[ok] rate limit: core 4000/5000, graphql 4000/5000 remaining\n",
+		},
+	}
+	defer func(f func(context.Context) (*api.Client, error)) { apiClientForContext = f }(apiClientForContext)
+	for _, tt := range tests {
+		responses := map[string]string{}
+		for k, v := range healthy {
+			responses[k] = v
+		}
+		for _, k := range tt.missing {
+			delete(responses, k)
+		}
+		if tt.teams != "" {
+			responses["GET /orgs/o/teams"] = tt.teams
+		}
+		fake := apitest.NewFakeGitHub(responses)
+		fake.Headers["GET /"] = http.Header{"X-Oauth-Scopes": {tt.scopes}}
+		apiClientForContext = func(context.Context) (*api.Client, error) {
+			return api.NewClient(api.ReplaceTripper(fake)), nil
+		}
+
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetOut(&out)
+		err := runDoctor(cmd, nil)
+		if out.String() != tt.want {
+			t.Errorf("%s: reported\n%s\nwant\n%s", tt.name, out.String(), tt.want)
+		}
+		if failed := tt.missing != nil; (err != nil) != failed {
+			t.Errorf("%s: doctor returned %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/mkrakowitzer/ghsettings/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [repository...]",
+	Short: "Generate config files from existing repositories",
+	Long: `Generate config files from existing repositories
+
+Reads the settings, collaborators, teams and branch protection rules of each
+repository and writes them to <output-dir>/<repository>.yaml. Use --all to
+export every repository in GITHUB_ORG. Archived repositories are skipped.`,
+	RunE: runExport,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().Bool("all", false, "Export every repository in the organisation")
+	exportCmd.Flags().StringP("output-dir", "o", "", "Directory to write config files to (default is GHSETTINGS_CONFIGDIR or repo_config)")
+	exportCmd.Flags().Bool("force", false, "Overwrite existing config files")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+
+	org := viper.GetString("GITHUB_ORG")
+
+	all, _ := cmd.Flags().GetBool("all")
+	force, _ := cmd.Flags().GetBool("force")
+	dir, _ := cmd.Flags().GetString("output-dir")
+	if dir == "" {
+		dir = configDir()
+	}
+
+	if !all && len(args) == 0 {
+		return fmt.Errorf("specify one or more repositories or --all")
+	}
+
+	apiClient, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+
+	repos := args
+	if all {
+		result, err := api.ListRepositories(apiClient, org)
+		if err != nil {
+			return err
+		}
+		for _, k := range result {
+			if k.Archived {
+				continue
+			}
+			repos = append(repos, k.Name)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, r := range repos {
+		f := filepath.Join(dir, fmt.Sprintf("%s.yaml", r))
+		if _, err := os.Stat(f); err == nil && !force {
+			log.WithFields(log.Fields{
+				"name": r,
+				"file": f,
+			}).Warn("config file exists, skipping")
+			continue
+		}
+
+		c, err := api.ExportRepository(apiClient, org, r)
+		if err != nil {
+			return err
+		}
+
+		data, err := yaml.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(f, data, 0644); err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"name": r,
+			"file": f,
+		}).Info("exported repository")
+	}
+	return nil
+}
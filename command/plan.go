@@ -0,0 +1,193 @@
+package command
+
+import (
+	"fmt"
+	"io"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/mkrakowitzer/ghsettings/api"
+	"github.com/mkrakowitzer/ghsettings/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the changes ghsettings would make without applying them",
+	Long: `Show the changes ghsettings would make without applying them
+
+The live state of every repository in the config is compared with the
+desired state and the differences are printed. No mutating requests are made.
+With --enforce, resources that would be removed are included.`,
+	RunE: runPlan,
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+
+	files, err := configFiles()
+	if err != nil {
+		return err
+	}
+
+	apiClient, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+
+	defaults, err := readDefaults()
+	if err != nil {
+		return err
+	}
+
+	enforce, _ := cmd.Flags().GetBool("enforce")
+
+	org := viper.GetString("GITHUB_ORG")
+	concurrency := viper.GetInt("concurrency")
+
+	state, err := loadSecretState(viper.GetString("secret-state"), hostname())
+	if err != nil {
+		return err
+	}
+
+	var orgPlan *api.Plan
+	if f := orgFile(); f != "" {
+		orgPlan = planOrg(cmd.OutOrStdout(), apiClient, org, f, enforce)
+	}
+
+	targets := expandTargets(apiClient, org, files, defaults)
+	plans := make([]*api.Plan, len(targets))
+	forEachFile(targetFiles(targets), concurrency, cmd.OutOrStdout(), func(i int, f string, logger *log.Logger, out io.Writer) {
+
+		if targets[i].Err != nil {
+			logger.WithFields(log.Fields{
+				"file": f,
+			}).Error(targets[i].Err)
+			return
+		}
+		config := targets[i].Config
+		logger.WithFields(log.Fields{
+			"name": config.Repository.Name,
+		}).Info("planning repository")
+
+		client := apiClient.WithLogger(logger.WithField("name", config.Repository.Name))
+		plan, err := api.PlanRepository(client, org, config, enforce)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"name": config.Repository.Name,
+			}).Error(err)
+			return
+		}
+		state.prune(plan)
+		printPlan(out, plan)
+		plans[i] = plan
+	})
+
+	if orgFile() != "" {
+		plans = append([]*api.Plan{orgPlan}, plans...)
+	}
+
+	var add, change, remove, failed int
+	for _, plan := range plans {
+		if plan == nil {
+			failed++
+			continue
+		}
+		add += plan.Count(api.ActionAdd)
+		change += plan.Count(api.ActionChange)
+		remove += plan.Count(api.ActionRemove)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Plan: %d to add, %d to change, %d to remove.\n", add, change, remove)
+
+	if failed > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d of %d plans could not be made", failed, len(plans))
+	}
+	return nil
+}
+
+// planOrg reads the org config file and prints the plan of the
+// organisation. It returns nil when the plan could not be made.
+func planOrg(w io.Writer, apiClient *api.Client, org string, file string, enforce bool) *api.Plan {
+
+	o, err := config.ReadOrg(file)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"file": file,
+		}).Error(err)
+		return nil
+	}
+	log.WithFields(log.Fields{
+		"name": org,
+	}).Info("planning organisation")
+
+	plan, err := api.PlanOrganization(apiClient, org, o, enforce)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"name": org,
+		}).Error(err)
+		return nil
+	}
+	printPlan(w, plan)
+	return plan
+}
+
+// planName names the repository of a plan, or the organisation for the
+// plan of the org config
+func planName(plan *api.Plan) string {
+	if plan.Repository == "" {
+		return plan.Org
+	}
+	return plan.Repository
+}
+
+func printPlan(w io.Writer, plan *api.Plan) {
+	if len(plan.Changes) == 0 {
+		fmt.Fprintf(w, "%s: no changes\n\n", planName(plan))
+		return
+	}
+
+	fmt.Fprintf(w, "%s:\n", planName(plan))
+	for _, c := range plan.Changes {
+		if c.Parent != "" {
+			fmt.Fprintf(w, "  %s %s %q in %q\n", c.Action, c.Resource, c.Name, c.Parent)
+		} else {
+			fmt.Fprintf(w, "  %s %s %q\n", c.Action, c.Resource, c.Name)
+		}
+		for _, f := range c.Fields {
+			switch c.Action {
+			case api.ActionAdd:
+				fmt.Fprintf(w, "      + %s = %s\n", f.Name, formatValue(f.New))
+			case api.ActionRemove:
+				fmt.Fprintf(w, "      - %s = %s\n", f.Name, formatValue(f.Old))
+			default:
+				fmt.Fprintf(w, "      ~ %s = %s -> %s\n", f.Name, formatValue(f.Old), formatValue(f.New))
+			}
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+func formatValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", t)
+	case []string:
+		s := "["
+		for i, k := range t {
+			if i > 0 {
+				s += ", "
+			}
+			s += fmt.Sprintf("%q", k)
+		}
+		return s + "]"
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mkrakowitzer/ghsettings/api"
+)
+
+func TestPrintPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		plan *api.Plan
+		want string
+	}{
+		{
+			name: "no changes",
+			plan: &api.Plan{Org: "o", Repository: "r"},
+			want: "r: no changes\n\n",
+		},
+		{
+			name: "organisation",
+			plan: &api.Plan{Org: "o", Changes: []api.Change{
+				{Action: api.ActionRemove, Resource: "org_variable", Name: "REGION", Fields: []api.FieldDiff{{Name: "value", Old: "eu"}}},
+			}},
+			want: "o:\n  - org_variable \"REGION\"\n      - value = \"eu\"\n\n",
+		},
+		{
+			name: "changes",
+			plan: &api.Plan{Org: "o", Repository: "r", Changes: []api.Change{
+				{Action: api.ActionChange, Resource: "repository", Name: "r", Fields: []api.FieldDiff{
+					{Name: "private", Old: false, New: true},
+					{Name: "description", Old: "", New: "Service"},
+				}},
+				{Action: api.ActionAdd, Resource: "collaborator", Name: "dev", Fields: []api.FieldDiff{{Name: "permission", New: "push"}}},
+				{Action: api.ActionChange, Resource: "topics", Name: "r", Fields: []api.FieldDiff{{Name: "names", Old: []string{}, New: []string{"go", "api"}}}},
+				{Action: api.ActionAdd, Resource: "environment_secret", Name: "TOKEN", Parent: "production", Fields: []api.FieldDiff{{Name: "value", New: api.Secret("s3cret")}}},
+			}},
+			want: `r:
+  ~ repository "r"
+      ~ private = false -> true
+      ~ description = "" -> "Service"
+  + collaborator "dev"
+      + permission = "push"
+  ~ topics "r"
+      ~ names = [] -> ["go", "api"]
+  + environment_secret "TOKEN" in "production"
+      + value = ********
+
+`,
+		},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		printPlan(&b, tt.plan)
+		if b.String() != tt.want {
+			t.Errorf("%s: printed\n%s\nwant\n%s", tt.name, b.String(), tt.want)
+		}
+	}
+}
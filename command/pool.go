@@ -0,0 +1,64 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// forEachFile calls fn for every file on up to concurrency workers. Each call
+// gets its own logger and output writer. Both are buffered and copied to the
+// standard logger and out in file order once a file and every file before it
+// are done, so the output of a repository is never interleaved with another.
+func forEachFile(files []string, concurrency int, out io.Writer, fn func(i int, f string, logger *log.Logger, out io.Writer)) {
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	type buffers struct {
+		log bytes.Buffer
+		out bytes.Buffer
+	}
+	bufs := make([]*buffers, len(files))
+	done := make([]bool, len(files))
+	next := 0
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				b := &buffers{}
+				logger := log.New()
+				logger.Out = &b.log
+				logger.Formatter = log.StandardLogger().Formatter
+				logger.Level = log.GetLevel()
+				fn(i, files[i], logger, &b.out)
+
+				mu.Lock()
+				bufs[i] = b
+				done[i] = true
+				for next < len(files) && done[next] {
+					io.Copy(log.StandardLogger().Out, &bufs[next].log)
+					io.Copy(out, &bufs[next].out)
+					bufs[next] = nil
+					next++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,53 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// TestForEachFileOrder finishes the files in another order than they are
+// listed and checks that their output and logs still come in file order
+func TestForEachFileOrder(t *testing.T) {
+	var logs bytes.Buffer
+	std := log.StandardLogger()
+	defer func(out io.Writer, formatter log.Formatter) { std.Out, std.Formatter = out, formatter }(std.Out, std.Formatter)
+	std.Out, std.Formatter = &logs, &log.TextFormatter{DisableTimestamp: true, DisableColors: true}
+
+	files := []string{"a.yaml", "b.yaml", "c.yaml", "d.yaml"}
+	release := make([]chan bool, len(files))
+	finished := make(chan int)
+	for i := range release {
+		release[i] = make(chan bool)
+	}
+	var out bytes.Buffer
+	done := make(chan bool)
+	go func() {
+		forEachFile(files, len(files), &out, func(i int, f string, logger *log.Logger, w io.Writer) {
+			<-release[i]
+			logger.Infof("planning %s", f)
+			fmt.Fprintf(w, "%s\n", f)
+			finished <- i
+		})
+		close(done)
+	}()
+	for _, i := range []int{3, 1, 2, 0} {
+		release[i] <- true
+		if got := <-finished; got != i {
+			t.Fatalf("file %d finished, want %d", got, i)
+		}
+	}
+	<-done
+
+	if want := "a.yaml\nb.yaml\nc.yaml\nd.yaml\n"; out.String() != want {
+		t.Errorf("output\n%s\nwant\n%s", out.String(), want)
+	}
+	want := "level=info msg=\"planning a.yaml\"\nlevel=info msg=\"planning b.yaml\"\n" +
+		"level=info msg=\"planning c.yaml\"\nlevel=info msg=\"planning d.yaml\"\n"
+	if logs.String() != want {
+		t.Errorf("logged\n%s\nwant\n%s", logs.String(), want)
+	}
+}
@@ -2,8 +2,11 @@ package command
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 
@@ -11,7 +14,6 @@ import (
 	"github.com/mkrakowitzer/ghsettings/config"
 	"github.com/mkrakowitzer/ghsettings/context"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
@@ -25,8 +27,10 @@ var rootCmd = &cobra.Command{
 	Short: "Configure GitHub repositories, collaborators, teams and branch protections",
 	Long: `Configure GitHub repositories, collaborators, teams and branch protections
 
-MU_GITHUB_TOKEN and GITHUB_ORG environment variables must be set`,
-	RunE: run,
+GITHUB_ORG must be set. A token is read from --token, MU_GITHUB_TOKEN, GH_TOKEN,
+GITHUB_TOKEN, the gh CLI hosts file, ~/.netrc or GHSETTINGS_TOKEN_COMMAND`,
+	RunE:          run,
+	SilenceErrors: true,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -49,9 +53,25 @@ func init() {
 	rootCmd.PersistentFlags().StringSlice("files", []string{}, "List of files seperated by spaces")
 	viper.BindPFlag("files", rootCmd.PersistentFlags().Lookup("files"))
 	viper.SetDefault("files", []string{})
+	rootCmd.PersistentFlags().String("defaults", "", "Defaults merged under every repository config (default is ./defaults.yaml if present)")
+	viper.BindPFlag("defaults", rootCmd.PersistentFlags().Lookup("defaults"))
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.PersistentFlags().BoolP("enforce", "e", false, "Enforce Collaborators, Teams and Branches")
+	rootCmd.PersistentFlags().IntP("concurrency", "c", 1, "Number of repositories to process in parallel")
+	viper.BindPFlag("concurrency", rootCmd.PersistentFlags().Lookup("concurrency"))
+	rootCmd.PersistentFlags().String("hostname", "", "GitHub Enterprise Server host, e.g. github.example.com (default is github.com)")
+	viper.BindPFlag("hostname", rootCmd.PersistentFlags().Lookup("hostname"))
+	rootCmd.PersistentFlags().String("token", "", "GitHub token, overrides any other token source")
+	viper.BindPFlag("token", rootCmd.PersistentFlags().Lookup("token"))
+	rootCmd.PersistentFlags().Int("rate-limit-floor", 50, "Wait for the rate limit to reset once fewer requests than this remain")
+	viper.BindPFlag("rate-limit-floor", rootCmd.PersistentFlags().Lookup("rate-limit-floor"))
+	rootCmd.PersistentFlags().Int("max-attempts", 4, "Attempts made for requests failing with a transient error")
+	viper.BindPFlag("max-attempts", rootCmd.PersistentFlags().Lookup("max-attempts"))
+	rootCmd.PersistentFlags().String("org-config", "", "Settings of the organisation itself, such as Actions variables (default is ./org.yaml if present)")
+	viper.BindPFlag("org-config", rootCmd.PersistentFlags().Lookup("org-config"))
+	rootCmd.PersistentFlags().String("secret-state", ".ghsettings-secrets.json", "File remembering the Actions and webhook secrets written, so unchanged secrets are skipped. Empty to always write them")
+	viper.BindPFlag("secret-state", rootCmd.PersistentFlags().Lookup("secret-state"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -72,8 +92,16 @@ func initConfig() {
 	}
 
 	viper.BindEnv("GITHUB_ORG")
-	viper.BindEnv("MU_GITHUB_TOKEN")
+	viper.BindEnv("token_command", "GHSETTINGS_TOKEN_COMMAND")
 	viper.BindEnv("GHSETTINGS_CONFIGDIR")
+	viper.BindEnv("defaults", "GHSETTINGS_DEFAULTS")
+	viper.BindEnv("secret-state", "GHSETTINGS_SECRET_STATE")
+	viper.BindEnv("org-config", "GHSETTINGS_ORG_CONFIG")
+	viper.BindEnv("hostname", "GH_HOST")
+	viper.BindEnv("app_id", "GHSETTINGS_APP_ID")
+	viper.BindEnv("app_private_key", "GHSETTINGS_APP_PRIVATE_KEY")
+	viper.BindEnv("app_private_key_file", "GHSETTINGS_APP_PRIVATE_KEY_FILE")
+	viper.BindEnv("app_installation_id", "GHSETTINGS_APP_INSTALLATION_ID")
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
@@ -83,84 +111,322 @@ func initConfig() {
 
 func run(cmd *cobra.Command, args []string) error {
 
-	var config config.C
+	files, err := configFiles()
+	if err != nil {
+		return err
+	}
 
-	Org := viper.GetString("GITHUB_ORG")
-	api.Org = Org
-	config_dir := viper.GetString("GHSETTINGS_CONFIGDIR")
-	if config_dir == "" {
-		config_dir = "repo_config"
+	apiClient, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	defaults, err := readDefaults()
+	if err != nil {
+		return err
 	}
 
-	var files []string
+	rate_start, _ := api.GetRateLimit(apiClient)
 
-	if len(viper.GetStringSlice("files")) == 0 {
-		f, err := ioutil.ReadDir(fmt.Sprintf("./%s", config_dir))
-		if err != nil {
-			log.Fatal(err)
-		}
-		for _, k := range f {
-			files = append(files, fmt.Sprintf("%s/%s", config_dir, k.Name()))
-		}
-	} else {
-		files = viper.GetStringSlice("files")
+	enforce, _ := cmd.Flags().GetBool("enforce")
+
+	org := viper.GetString("GITHUB_ORG")
+	concurrency := viper.GetInt("concurrency")
+
+	state, err := loadSecretState(viper.GetString("secret-state"), hostname())
+	if err != nil {
+		return err
 	}
 
-	ctx := context.New()
+	var results []result
+	if f := orgFile(); f != "" {
+		results = append(results, reconcileOrg(apiClient, org, f, enforce))
+	}
 
-	apiClient, err := apiClientForContext(ctx)
+	targets := expandTargets(apiClient, org, files, defaults)
+	repoResults := make([]result, len(targets))
+	forEachFile(targetFiles(targets), concurrency, cmd.OutOrStdout(), func(i int, f string, logger *log.Logger, out io.Writer) {
+		repoResults[i] = reconcile(apiClient, org, targets[i], enforce, state, logger)
+	})
+	results = append(results, repoResults...)
+	if err := state.save(); err != nil {
+		log.Errorf("saving secret state: %s", err)
+	}
+
+	summary := &summary{results: results}
+
+	rate_end, _ := api.GetRateLimit(apiClient)
+	log.WithFields(log.Fields{
+		"core_api_calls":     rate_start.Resources.Core.Remaining - rate_end.Resources.Core.Remaining,
+		"graphql_ap_calls":   rate_start.Resources.Graphql.Remaining - rate_end.Resources.Graphql.Remaining,
+		"core_remaining":     rate_end.Resources.Core.Remaining,
+		"graphql_remaining":  rate_end.Resources.Graphql.Remaining,
+		"combined_remaining": rate_end.Rate.Remaining,
+	}).Info("rate limit stats")
+
+	summary.print(cmd.OutOrStdout())
+
+	cmd.SilenceUsage = true
+	return summary.err()
+}
+
+// reconcile brings a single repository in line with its config file. Errors
+// are recorded in the result rather than returned so that the remaining
+// repositories are still processed.
+func reconcile(apiClient *api.Client, org string, t target, enforce bool, state *secretState, logger *log.Logger) result {
+
+	res := result{File: t.File, Repository: t.Repository}
+
+	if t.Err != nil {
+		logger.WithFields(log.Fields{
+			"file": t.File,
+		}).Error(t.Err)
+		res.Status = statusSkipped
+		res.Errors = append(res.Errors, t.Err)
+		return res
+	}
+	config := t.Config
+	logger.WithFields(log.Fields{
+		"name": config.Repository.Name,
+	}).Info("applying to repository")
+	apiClient = apiClient.WithLogger(logger.WithField("name", config.Repository.Name))
+
+	plan, err := api.PlanRepository(apiClient, org, config, enforce)
 	if err != nil {
-		log.Fatal(err)
+		logger.WithFields(log.Fields{
+			"name": config.Repository.Name,
+		}).Error(err)
+		res.Status = statusFailed
+		res.Errors = append(res.Errors, err)
+		return res
 	}
-	rate_start, _ := api.GetRateLimit(apiClient)
+	state.prune(plan)
 
-	for _, f := range files {
+	return applyPlan(apiClient, plan, state, logger, res)
+}
 
-		data, err := ioutil.ReadFile(f)
-		if err != nil {
-			log.Fatal(err)
+// reconcileOrg brings the organisation in line with the org config file
+func reconcileOrg(apiClient *api.Client, org string, file string, enforce bool) result {
+
+	res := result{File: file, Repository: org}
+	logger := log.StandardLogger()
+
+	o, err := config.ReadOrg(file)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"file": file,
+		}).Error(err)
+		res.Status = statusSkipped
+		res.Errors = append(res.Errors, err)
+		return res
+	}
+	logger.WithFields(log.Fields{
+		"name": org,
+	}).Info("applying to organisation")
+
+	plan, err := api.PlanOrganization(apiClient, org, o, enforce)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"name": org,
+		}).Error(err)
+		res.Status = statusFailed
+		res.Errors = append(res.Errors, err)
+		return res
+	}
+
+	return applyPlan(apiClient, plan, nil, logger, res)
+}
+
+// applyPlan applies a plan and records the outcome in res. Written secrets
+// are recorded in state, which is nil for plans without secrets.
+func applyPlan(apiClient *api.Client, plan *api.Plan, state *secretState, logger *log.Logger, res result) result {
+
+	name := planName(plan)
+	res.Status = statusSucceeded
+	res.Changes = len(plan.Changes)
+	if len(plan.Changes) == 0 {
+		logger.WithFields(log.Fields{
+			"name": name,
+		}).Info("unchanged")
+		return res
+	}
+	for _, c := range plan.Changes {
+		logger.WithFields(log.Fields{
+			"name":          name,
+			"action":        c.Action,
+			"resource":      c.Resource,
+			"resource_name": c.Name,
+		}).Info("changing")
+	}
+
+	err := api.ApplyPlan(apiClient, plan)
+	errs, _ := err.(api.ApplyErrors)
+	if state != nil {
+		if err := state.record(apiClient, plan, errs); err != nil {
+			logger.WithFields(log.Fields{
+				"name": name,
+			}).Warn("could not record written secrets: ", err)
 		}
-		if err := yaml.Unmarshal(data, &config); err != nil {
-			log.Fatal(err)
+	}
+	if errs != nil {
+		for _, e := range errs {
+			logger.WithFields(log.Fields{
+				"name":          name,
+				"resource":      e.Change.Resource,
+				"resource_name": e.Change.Name,
+			}).Error(e.Err)
+			res.Errors = append(res.Errors, e)
 		}
-		log.WithFields(log.Fields{
-			"name": config.Repository.Name,
-		}).Info("applying to repository")
+		res.Status = statusFailed
+	} else if err != nil {
+		res.Status = statusFailed
+		res.Errors = append(res.Errors, err)
+	}
+	return res
+}
 
-		repo, err := api.GetRepoID(apiClient, Org, config.Repository.Name)
-		if err != nil {
-			log.Fatal(err)
-		}
+// targetFiles returns the config file of every target
+func targetFiles(targets []target) []string {
+	files := make([]string, len(targets))
+	for i, t := range targets {
+		files[i] = t.File
+	}
+	return files
+}
 
-		err = api.UpdateRepository(apiClient, repo, config)
-		if err != nil {
-			log.Fatal(err)
+// configFiles returns the repository config files to process, either from
+// --files or every file in GHSETTINGS_CONFIGDIR.
+func configFiles() ([]string, error) {
+
+	config_dir := configDir()
+
+	if len(viper.GetStringSlice("files")) != 0 {
+		return viper.GetStringSlice("files"), nil
+	}
+
+	var files []string
+	f, err := ioutil.ReadDir(fmt.Sprintf("./%s", config_dir))
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range f {
+		files = append(files, fmt.Sprintf("%s/%s", config_dir, k.Name()))
+	}
+	return files, nil
+}
+
+// configDir returns the directory holding the repository config files
+func configDir() string {
+	config_dir := viper.GetString("GHSETTINGS_CONFIGDIR")
+	if config_dir == "" {
+		config_dir = "repo_config"
+	}
+	return config_dir
+}
+
+// defaultsFile returns the defaults file merged under every repository
+// config, or an empty string when none is in use
+func defaultsFile() string {
+	f := viper.GetString("defaults")
+	if f == "" {
+		if _, err := os.Stat("defaults.yaml"); err != nil {
+			return ""
 		}
+		f = "defaults.yaml"
+	}
+	return f
+}
 
-		err = api.UpdateCollaborator(apiClient, config, cmd)
-		if err != nil {
-			log.Fatal(err)
+// orgFile returns the org config file, or an empty string when the
+// organisation itself is not managed
+func orgFile() string {
+	f := viper.GetString("org-config")
+	if f == "" {
+		if _, err := os.Stat("org.yaml"); err != nil {
+			return ""
 		}
+		f = "org.yaml"
+	}
+	return f
+}
 
-		err = api.UpdateTeam(apiClient, config, cmd)
-		if err != nil {
-			log.Fatal(err)
+// readDefaults returns the contents of the defaults file merged under every
+// repository config, once it is checked. It is empty when no defaults file
+// is in use.
+func readDefaults() ([]byte, error) {
+	f := defaultsFile()
+	if f == "" {
+		return nil, nil
+	}
+	return config.ReadDefaults(f)
+}
+
+// hostname returns the GitHub host ghsettings talks to. A scheme or path
+// given with it, e.g. https://github.example.com/, is dropped so the host
+// matches the token lookups; the API is always reached over https.
+func hostname() string {
+	h := viper.GetString("hostname")
+	if i := strings.Index(h, "://"); i >= 0 {
+		h = h[i+3:]
+	}
+	if i := strings.Index(h, "/"); i >= 0 {
+		h = h[:i]
+	}
+	if h == "" {
+		return context.DefaultHostname
+	}
+	return strings.ToLower(h)
+}
+
+// newContext returns a Context for the configured host. When app_id is set it
+// authenticates as a GitHub App installation, otherwise with the first token
+// found in --token, the config file, the environment, the gh CLI hosts file,
+// ~/.netrc or the output of token_command. Tokens for each host can be set in
+// the config file:
+//
+//	hosts:
+//	  github.example.com:
+//	    token: foobarbaz
+func newContext() (context.Context, error) {
+	if appID := viper.GetInt64("app_id"); appID != 0 {
+		key := []byte(viper.GetString("app_private_key"))
+		if f := viper.GetString("app_private_key_file"); f != "" {
+			var err error
+			key, err = ioutil.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
 		}
+		return context.NewForApp(hostname(), context.AppCredentials{
+			AppID:          appID,
+			PrivateKey:     key,
+			InstallationID: viper.GetInt64("app_installation_id"),
+			Org:            viper.GetString("GITHUB_ORG"),
+		}), nil
+	}
 
-		err = api.BranchProtections(apiClient, repo, config, cmd)
-		if err != nil {
-			log.Fatal(err)
+	tokens := map[string]string{}
+	for host, v := range viper.GetStringMap("hosts") {
+		settings, _ := v.(map[string]interface{})
+		if t, ok := settings["token"].(string); ok && t != "" {
+			tokens[host] = t
 		}
 	}
-	rate_end, _ := api.GetRateLimit(apiClient)
-	log.WithFields(log.Fields{
-		"core_api_calls":     rate_start.Resources.Core.Remaining - rate_end.Resources.Core.Remaining,
-		"graphql_ap_calls":   rate_start.Resources.Graphql.Remaining - rate_end.Resources.Graphql.Remaining,
-		"core_remaining":     rate_end.Resources.Core.Remaining,
-		"graphql_remaining":  rate_end.Resources.Graphql.Remaining,
-		"combined_remaining": rate_end.Rate.Remaining,
-	}).Info("rate limit stats")
-	return nil
+	sources := append([]context.TokenSource{
+		context.StaticToken("--token", viper.GetString("token")),
+		context.HostTokens("hosts in config file", tokens),
+	}, context.DefaultTokenSources()...)
+	sources = append(sources, context.CommandToken(viper.GetString("token_command")))
+
+	return context.NewForHost(hostname(), sources...), nil
+}
+
+// newAPIClient returns a Client for the configured host and credentials
+func newAPIClient() (*api.Client, error) {
+	ctx, err := newContext()
+	if err != nil {
+		return nil, err
+	}
+	return apiClientForContext(ctx)
 }
 
 var apiClientForContext = func(ctx context.Context) (*api.Client, error) {
@@ -173,17 +439,28 @@ var apiClientForContext = func(ctx context.Context) (*api.Client, error) {
 	if verbose := os.Getenv("DEBUG"); verbose != "" {
 		opts = append(opts, api.ApiVerboseLog())
 	}
+	opts = append(opts,
+		api.Retry(viper.GetInt("max-attempts"), time.Second),
+		api.RateLimit(viper.GetInt("rate-limit-floor")),
+	)
+	// Tokens that expire, such as GitHub App installation tokens, are renewed
+	// by the Context so it is asked again for every request.
 	getAuthValue := func() string {
-		return fmt.Sprintf("token %s", token)
+		t, err := ctx.AuthToken()
+		if err != nil {
+			log.Error(err)
+			return fmt.Sprintf("token %s", token)
+		}
+		return fmt.Sprintf("token %s", t)
 	}
 
 	Version := "1"
 	opts = append(opts,
 		api.AddHeaderFunc("Authorization", getAuthValue),
 		api.AddHeader("User-Agent", fmt.Sprintf("ghsettings %s", Version)),
-		api.AddHeader("Accept", "application/vnd.github.antiope-preview+json"),
+		api.AddHeader("Accept", "application/vnd.github.antiope-preview+json, application/vnd.github.mercy-preview+json"),
 	)
 
-	return api.NewClient(opts...), nil
+	return api.NewClientForHost(ctx.Hostname(), opts...), nil
 
 }
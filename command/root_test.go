@@ -0,0 +1,27 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestHostname(t *testing.T) {
+	defer viper.Set("hostname", "")
+	tests := []struct {
+		flag string
+		want string
+	}{
+		{"", "github.com"},
+		{"github.example.com", "github.example.com"},
+		{"GitHub.Example.com", "github.example.com"},
+		{"https://github.example.com", "github.example.com"},
+		{"https://github.example.com/api/v3/", "github.example.com"},
+	}
+	for _, tt := range tests {
+		viper.Set("hostname", tt.flag)
+		if got := hostname(); got != tt.want {
+			t.Errorf("hostname() with --hostname %q = %s, want %s", tt.flag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/mkrakowitzer/ghsettings/config"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the config file format",
+	Long: `Print the JSON Schema of the config file format
+
+The schema is generated from the config types and describes every setting,
+including the allowed permissions. Point yaml-language-server at it to get
+completion and validation of repo_config/*.yaml in your editor, e.g. by adding
+this line at the top of a config file:
+
+	# yaml-language-server: $schema=../ghsettings.schema.json`,
+	RunE: runSchema,
+}
+
+func init() {
+	schemaCmd.Flags().StringP("output", "o", "", "Write the schema to this file instead of stdout")
+	rootCmd.AddCommand(schemaCmd)
+}
+
+func runSchema(cmd *cobra.Command, args []string) error {
+
+	schema, err := config.Schema()
+	if err != nil {
+		return err
+	}
+	schema = append(schema, '\n')
+
+	output, _ := cmd.Flags().GetString("output")
+	if output == "" {
+		_, err = cmd.OutOrStdout().Write(schema)
+		return err
+	}
+	if err := ioutil.WriteFile(output, schema, 0644); err != nil {
+		return fmt.Errorf("writing schema: %s", err)
+	}
+	return nil
+}
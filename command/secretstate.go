@@ -0,0 +1,230 @@
+package command
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkrakowitzer/ghsettings/api"
+)
+
+// secretFields names the field holding the secret value of each plan
+// resource whose secret can not be read back from GitHub and is tracked in
+// the secret state
+var secretFields = map[string]string{
+	"secret":             "value",
+	"environment_secret": "value",
+	"webhook":            "secret",
+}
+
+// secretField returns the index of the field of a change holding a secret
+// value, or -1 when it has none
+func secretField(c api.Change) int {
+	name, ok := secretFields[c.Resource]
+	if !ok {
+		return -1
+	}
+	for i, f := range c.Fields {
+		if _, isSecret := f.New.(api.Secret); isSecret && f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// secretEntry records the last value written to a secret as a salted HMAC,
+// together with the time GitHub reported the secret was updated afterwards
+type secretEntry struct {
+	Salt      string    `json:"salt"`
+	Hash      string    `json:"hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// secretState remembers what ghsettings last wrote to each secret, Actions or
+// webhook, so that unchanged secrets are not uploaded on every run. A secret
+// is uploaded again when its value changed or someone else updated it, or
+// its webhook, on GitHub since.
+type secretState struct {
+	path string
+	host string
+
+	mu      sync.Mutex
+	Entries map[string]secretEntry `json:"secrets"`
+}
+
+// loadSecretState reads the state file at path. An empty path disables the
+// state, so every secret is uploaded. A missing file is an empty state.
+func loadSecretState(path string, host string) (*secretState, error) {
+	s := &secretState{path: path, host: host, Entries: map[string]secretEntry{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if s.Entries == nil {
+		s.Entries = map[string]secretEntry{}
+	}
+	return s, nil
+}
+
+// save writes the state file, readable only by the current user
+func (s *secretState) save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, append(data, '\n'), 0600)
+}
+
+func (s *secretState) key(plan *api.Plan, c api.Change) string {
+	parts := []string{s.host, plan.Org, plan.Repository, c.Resource, c.Name}
+	if c.Parent != "" {
+		parts = []string{s.host, plan.Org, plan.Repository, c.Resource, c.Parent, c.Name}
+	}
+	return strings.ToLower(strings.Join(parts, "/"))
+}
+
+// prune drops the secrets whose value and GitHub update time are the same as
+// when ghsettings last wrote them from the plan. A change left without fields
+// is dropped altogether.
+func (s *secretState) prune(plan *api.Plan) {
+	if s.path == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes := plan.Changes[:0]
+	for _, c := range plan.Changes {
+		if i := secretField(c); i >= 0 && c.Action == api.ActionChange {
+			e, ok := s.Entries[s.key(plan, c)]
+			value := c.Fields[i].New.(api.Secret)
+			if ok && e.UpdatedAt.Equal(c.UpdatedAt) && hmac.Equal([]byte(e.Hash), []byte(secretHash(e.Salt, value))) {
+				c.Fields = append(append([]api.FieldDiff{}, c.Fields[:i]...), c.Fields[i+1:]...)
+				if len(c.Fields) == 0 {
+					continue
+				}
+			}
+		}
+		changes = append(changes, c)
+	}
+	plan.Changes = changes
+}
+
+// record stores the secrets written by a plan, leaving out the changes that
+// failed. The update time of every written secret, and of every changed
+// webhook with a known secret, is read back from GitHub.
+func (s *secretState) record(client *api.Client, plan *api.Plan, failed api.ApplyErrors) error {
+	if s.path == "" {
+		return nil
+	}
+	for _, c := range plan.Changes {
+		if _, ok := secretFields[c.Resource]; !ok || changeFailed(c, failed) {
+			continue
+		}
+		key := s.key(plan, c)
+		if c.Action == api.ActionRemove {
+			s.mu.Lock()
+			delete(s.Entries, key)
+			s.mu.Unlock()
+			continue
+		}
+		i := secretField(c)
+		s.mu.Lock()
+		e, known := s.Entries[key]
+		s.mu.Unlock()
+		if i < 0 && !known {
+			continue
+		}
+
+		updatedAt, err := secretUpdatedAt(client, plan, c)
+		if err != nil {
+			return err
+		}
+		if i < 0 {
+			// a webhook whose other settings changed keeps its secret
+			e.UpdatedAt = updatedAt
+			s.mu.Lock()
+			s.Entries[key] = e
+			s.mu.Unlock()
+			continue
+		}
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		e = secretEntry{Salt: base64.StdEncoding.EncodeToString(salt), UpdatedAt: updatedAt}
+		e.Hash = secretHash(e.Salt, c.Fields[i].New.(api.Secret))
+
+		s.mu.Lock()
+		s.Entries[key] = e
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// secretUpdatedAt reads back when GitHub last updated the secret written by
+// a change, or the webhook holding it
+func secretUpdatedAt(client *api.Client, plan *api.Plan, c api.Change) (time.Time, error) {
+	var info *api.SecretInfo
+	var err error
+	switch c.Resource {
+	case "secret":
+		info, err = api.GetSecret(client, plan.Org, plan.Repository, c.Name)
+	case "environment_secret":
+		info, err = api.GetEnvironmentSecret(client, plan.Org, plan.Repository, c.Parent, c.Name)
+	case "webhook":
+		hooks, err := api.ListWebhooks(client, plan.Org, plan.Repository)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, k := range hooks {
+			if k.Config.URL == c.Name {
+				return k.UpdatedAt, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("webhook %s not found after writing it", c.Name)
+	default:
+		return time.Time{}, fmt.Errorf("unknown secret resource '%s'", c.Resource)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.UpdatedAt, nil
+}
+
+func changeFailed(c api.Change, failed api.ApplyErrors) bool {
+	for _, e := range failed {
+		if e.Change.Resource == c.Resource && e.Change.Parent == c.Parent && e.Change.Name == c.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// secretHash returns the base64 HMAC-SHA256 of value keyed with salt
+func secretHash(salt string, value api.Secret) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,173 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkrakowitzer/ghsettings/api"
+	"github.com/mkrakowitzer/ghsettings/api/apitest"
+)
+
+// tempState returns an empty secret state kept in a temporary directory and
+// a function removing it
+func tempState(t *testing.T) (*secretState, func()) {
+	dir, err := ioutil.TempDir("", "secretstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadSecretState(filepath.Join(dir, "state.json"), "github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func fieldNames(c api.Change) []string {
+	var names []string
+	for _, f := range c.Fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestSecretStateWebhook(t *testing.T) {
+	const url = "https://ci.example.com/hook"
+	written := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	client := api.NewClient(api.ReplaceTripper(apitest.NewFakeGitHub(map[string]string{
+		"GET /repos/o/r/hooks": `[{"id":5,"config":{"url":"` + url + `"},"updated_at":"2024-05-01T12:00:00Z"}]`,
+	})))
+	hook := func(secret string, updatedAt time.Time, fields ...api.FieldDiff) *api.Plan {
+		fields = append(fields, api.FieldDiff{Name: "secret", Old: api.Secret(""), New: api.Secret(secret)})
+		return &api.Plan{Org: "o", Repository: "r", Changes: []api.Change{
+			{Action: api.ActionChange, Resource: "webhook", Name: url, ID: int64(5), Fields: fields, UpdatedAt: updatedAt},
+		}}
+	}
+	events := api.FieldDiff{Name: "events", Old: []string{"push"}, New: []string{"push", "release"}}
+
+	s, cleanup := tempState(t)
+	defer cleanup()
+	plan := hook("s3cret", written.Add(-time.Hour))
+	s.prune(plan)
+	if len(plan.Changes) != 1 {
+		t.Fatalf("unknown secret was pruned")
+	}
+	if err := s.record(client, plan, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadSecretState(s.path, "github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		plan   *api.Plan
+		fields []string
+	}{
+		{"unchanged", hook("s3cret", written), nil},
+		{"rotated", hook("n3w", written), []string{"secret"}},
+		{"hook edited on GitHub", hook("s3cret", written.Add(time.Minute)), []string{"secret"}},
+		{"other settings changed", hook("s3cret", written, events), []string{"events"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.prune(tt.plan)
+			var got []string
+			if len(tt.plan.Changes) > 0 {
+				got = fieldNames(tt.plan.Changes[0])
+			}
+			if strings.Join(got, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("fields left %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestSecretStateActions(t *testing.T) {
+	written := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	client := api.NewClient(api.ReplaceTripper(apitest.NewFakeGitHub(map[string]string{
+		"GET /repos/o/r/actions/secrets/NPM_TOKEN":                    `{"name":"NPM_TOKEN","updated_at":"2024-05-01T12:00:00Z"}`,
+		"GET /repos/o/r/environments/production/secrets/DEPLOY_TOKEN": `{"name":"DEPLOY_TOKEN","updated_at":"2024-05-01T12:00:00Z"}`,
+	})))
+	secret := func(action api.Action, resource, parent, name, value string, updatedAt time.Time) api.Change {
+		c := api.Change{Action: action, Resource: resource, Parent: parent, Name: name, UpdatedAt: updatedAt,
+			Fields: []api.FieldDiff{{Name: "value", New: api.Secret(value)}}}
+		if action != api.ActionAdd {
+			c.Fields[0].Old = api.Secret("")
+		}
+		return c
+	}
+	plan := func(changes ...api.Change) *api.Plan {
+		return &api.Plan{Org: "o", Repository: "r", Changes: changes}
+	}
+
+	s, cleanup := tempState(t)
+	defer cleanup()
+	failing := secret(api.ActionChange, "secret", "", "SIGNING_KEY", "k", written)
+	applied := plan(
+		secret(api.ActionAdd, "secret", "", "NPM_TOKEN", "npm", time.Time{}),
+		secret(api.ActionChange, "environment_secret", "production", "DEPLOY_TOKEN", "d", written.Add(-time.Hour)),
+		failing,
+	)
+	if err := s.record(client, applied, api.ApplyErrors{{Change: failing}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		plan  *api.Plan
+		names []string
+	}{
+		{"unchanged", plan(secret(api.ActionChange, "secret", "", "NPM_TOKEN", "npm", written)), nil},
+		{"name matched ignoring case", plan(secret(api.ActionChange, "secret", "", "npm_token", "npm", written)), nil},
+		{"rotated", plan(secret(api.ActionChange, "secret", "", "NPM_TOKEN", "new", written)), []string{"NPM_TOKEN"}},
+		{"updated on GitHub", plan(secret(api.ActionChange, "secret", "", "NPM_TOKEN", "npm", written.Add(time.Minute))), []string{"NPM_TOKEN"}},
+		{"recreated after it was deleted", plan(secret(api.ActionAdd, "secret", "", "NPM_TOKEN", "npm", time.Time{})), []string{"NPM_TOKEN"}},
+		{"environment secret", plan(secret(api.ActionChange, "environment_secret", "production", "DEPLOY_TOKEN", "d", written)), nil},
+		{"same name in another environment", plan(secret(api.ActionChange, "environment_secret", "staging", "DEPLOY_TOKEN", "d", written)), []string{"DEPLOY_TOKEN"}},
+		{"failed write not recorded", plan(failing), []string{"SIGNING_KEY"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.prune(tt.plan)
+			var got []string
+			for _, c := range tt.plan.Changes {
+				got = append(got, c.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.names, ",") {
+				t.Errorf("secrets left %v, want %v", got, tt.names)
+			}
+		})
+	}
+
+	removed := plan(api.Change{Action: api.ActionRemove, Resource: "secret", Name: "NPM_TOKEN",
+		Fields: []api.FieldDiff{{Name: "value", Old: api.Secret("")}}})
+	if err := s.record(client, removed, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Entries[s.key(removed, removed.Changes[0])]; ok {
+		t.Error("removed secret is still recorded")
+	}
+}
+
+func TestSecretStateDisabled(t *testing.T) {
+	s, err := loadSecretState("", "github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Entries["github.com/o/r/secret/npm_token"] = secretEntry{Salt: "s", Hash: secretHash("s", "npm")}
+	plan := &api.Plan{Org: "o", Repository: "r", Changes: []api.Change{
+		{Action: api.ActionChange, Resource: "secret", Name: "NPM_TOKEN", Fields: []api.FieldDiff{{Name: "value", Old: api.Secret(""), New: api.Secret("npm")}}},
+	}}
+	s.prune(plan)
+	if len(plan.Changes) != 1 {
+		t.Error("secret pruned without a state file")
+	}
+}
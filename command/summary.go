@@ -0,0 +1,65 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+const (
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+	statusSkipped   = "skipped"
+)
+
+// result is the outcome of reconciling a single config file
+type result struct {
+	File       string
+	Repository string
+	Status     string
+	Changes    int
+	Errors     []error
+}
+
+// summary collects the result of every config file in a run
+type summary struct {
+	results []result
+}
+
+func (s *summary) count(status string) int {
+	n := 0
+	for _, r := range s.results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// print writes a table of every repository followed by the totals
+func (s *summary) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tFILE\tSTATUS\tCHANGES\tERRORS")
+	for _, r := range s.results {
+		errors := ""
+		for i, e := range r.Errors {
+			if i > 0 {
+				errors += "; "
+			}
+			errors += e.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", r.Repository, r.File, r.Status, r.Changes, errors)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d succeeded, %d failed, %d skipped\n",
+		s.count(statusSucceeded), s.count(statusFailed), s.count(statusSkipped))
+}
+
+// err returns an error if any config file failed or was skipped
+func (s *summary) err() error {
+	failed := s.count(statusFailed) + s.count(statusSkipped)
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d repositories were not reconciled", failed, len(s.results))
+}
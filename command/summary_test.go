@@ -0,0 +1,46 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	s := &summary{results: []result{
+		{File: "repo_config/api.yaml", Repository: "api", Status: statusSucceeded, Changes: 2},
+		{File: "repo_config/web.yaml", Repository: "web", Status: statusFailed, Changes: 3,
+			Errors: []error{errors.New("label 'bug': 422"), errors.New("team 'docs': not found")}},
+		{File: "repo_config/old.yaml", Status: statusSkipped, Errors: []error{errors.New("invalid config")}},
+	}}
+	var b bytes.Buffer
+	s.print(&b)
+	want := "REPOSITORY  FILE                  STATUS     CHANGES  ERRORS\n" +
+		"api         repo_config/api.yaml  succeeded  2        \n" +
+		"web         repo_config/web.yaml  failed     3        label 'bug': 422; team 'docs': not found\n" +
+		"            repo_config/old.yaml  skipped    0        invalid config\n" +
+		"\n1 succeeded, 1 failed, 1 skipped\n"
+	if b.String() != want {
+		t.Errorf("printed\n%s\nwant\n%s", b.String(), want)
+	}
+
+	tests := []struct {
+		name    string
+		results []result
+		err     string
+	}{
+		{"no repositories", nil, ""},
+		{"all succeeded", s.results[:1], ""},
+		{"one failed", s.results[:2], "1 of 2 repositories were not reconciled"},
+		{"failed and skipped", s.results, "2 of 3 repositories were not reconciled"},
+	}
+	for _, tt := range tests {
+		var got string
+		if err := (&summary{results: tt.results}).err(); err != nil {
+			got = err.Error()
+		}
+		if got != tt.err {
+			t.Errorf("%s: error %q, want %q", tt.name, got, tt.err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/mkrakowitzer/ghsettings/api"
+	"github.com/mkrakowitzer/ghsettings/config"
+)
+
+// target is a repository and the config file that claimed it. Err is set
+// when the file could not be read or more than one file claimed the
+// repository, and the repository must be skipped.
+type target struct {
+	File       string
+	Repository string
+	Config     config.C
+	Match      string
+	Err        error
+}
+
+// claim is a config file selecting a repository. Exact claims, by
+// repository.name or a name listed in a selector, win over patterns.
+type claim struct {
+	file  int
+	match string
+	exact bool
+}
+
+// expandTargets reads every config file and works out the repositories it
+// applies to. Files with a repositories selector are matched against the
+// repositories of the organisation, which are only listed when such a file
+// exists. Every repository is claimed by a single file: an exact claim wins
+// over a pattern, while two claims of the same kind are an error.
+func expandTargets(client *api.Client, org string, files []string, defaults []byte) []target {
+
+	configs := make([]config.C, len(files))
+	var targets []target
+	var selectors []int
+	for i, f := range files {
+		c, err := config.Read(f, defaults)
+		if err != nil {
+			targets = append(targets, target{File: f, Err: err})
+			continue
+		}
+		configs[i] = c
+		if c.Repositories != nil {
+			selectors = append(selectors, i)
+		}
+	}
+
+	claims := map[string][]claim{}
+	var names []string
+	add := func(name string, c claim) {
+		key := strings.ToLower(name)
+		if _, ok := claims[key]; !ok {
+			names = append(names, name)
+		}
+		claims[key] = append(claims[key], c)
+	}
+
+	for i, c := range configs {
+		if c.Repository.Name != "" {
+			add(c.Repository.Name, claim{file: i, match: "name", exact: true})
+		}
+	}
+	if len(selectors) > 0 {
+		repos, err := api.ListRepositories(client, org)
+		if err != nil {
+			for _, i := range selectors {
+				targets = append(targets, target{File: files[i], Err: fmt.Errorf("listing repositories of %s: %s", org, err)})
+			}
+			selectors = nil
+		}
+		sort.Slice(repos, func(a, b int) bool { return repos[a].Name < repos[b].Name })
+		for _, i := range selectors {
+			s := configs[i].Repositories
+			matched := 0
+			for _, r := range repos {
+				if r.Archived {
+					continue
+				}
+				if match, ok := s.Match(r.Name, r.Topics); ok {
+					add(r.Name, claim{file: i, match: match, exact: s.Named(r.Name)})
+					matched++
+				}
+			}
+			if matched == 0 {
+				log.WithFields(log.Fields{
+					"file": files[i],
+				}).Warn("repositories selector matches no repository")
+			}
+		}
+	}
+
+	for _, name := range names {
+		winners := winningClaims(claims[strings.ToLower(name)])
+		first := winners[0]
+		t := target{File: files[first.file], Repository: name, Config: configs[first.file], Match: first.match}
+		t.Config.Repository.Name = name
+		t.Config.Repositories = nil
+		if len(winners) > 1 {
+			var claimed []string
+			for _, w := range winners {
+				claimed = append(claimed, fmt.Sprintf("%s (%s)", files[w.file], w.match))
+			}
+			t.Err = fmt.Errorf("%s is claimed by more than one config file: %s", name, strings.Join(claimed, ", "))
+		}
+		targets = append(targets, t)
+	}
+
+	sort.SliceStable(targets, func(a, b int) bool { return fileIndex(files, targets[a].File) < fileIndex(files, targets[b].File) })
+	for _, t := range targets {
+		if t.Err != nil || t.Match == "name" {
+			continue
+		}
+		log.WithFields(log.Fields{
+			"file":  t.File,
+			"name":  t.Repository,
+			"match": t.Match,
+		}).Info("file claimed repository")
+	}
+	return targets
+}
+
+// winningClaims returns the exact claims, or all claims when none is exact.
+// A file claiming a repository twice counts once.
+func winningClaims(claims []claim) []claim {
+	exact := false
+	for _, c := range claims {
+		exact = exact || c.exact
+	}
+	var winners []claim
+	seen := map[int]bool{}
+	for _, c := range claims {
+		if c.exact == exact && !seen[c.file] {
+			seen[c.file] = true
+			winners = append(winners, c)
+		}
+	}
+	return winners
+}
+
+func fileIndex(files []string, f string) int {
+	for i, k := range files {
+		if k == f {
+			return i
+		}
+	}
+	return len(files)
+}
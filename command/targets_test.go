@@ -0,0 +1,96 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mkrakowitzer/ghsettings/api"
+	"github.com/mkrakowitzer/ghsettings/api/apitest"
+)
+
+func TestWinningClaims(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims []claim
+		want   []claim
+	}{
+		{
+			name:   "single pattern",
+			claims: []claim{{file: 0, match: "glob svc-*"}},
+			want:   []claim{{file: 0, match: "glob svc-*"}},
+		},
+		{
+			name:   "exact claim wins over patterns",
+			claims: []claim{{file: 0, match: "glob svc-*"}, {file: 1, match: "name", exact: true}, {file: 2, match: "topic go"}},
+			want:   []claim{{file: 1, match: "name", exact: true}},
+		},
+		{
+			name:   "two patterns conflict",
+			claims: []claim{{file: 0, match: "glob svc-*"}, {file: 1, match: "topic go"}},
+			want:   []claim{{file: 0, match: "glob svc-*"}, {file: 1, match: "topic go"}},
+		},
+		{
+			name:   "two exact claims conflict",
+			claims: []claim{{file: 0, match: "name", exact: true}, {file: 1, match: "name", exact: true}},
+			want:   []claim{{file: 0, match: "name", exact: true}, {file: 1, match: "name", exact: true}},
+		},
+		{
+			name:   "a file claiming twice counts once",
+			claims: []claim{{file: 0, match: "name", exact: true}, {file: 0, match: "name", exact: true}},
+			want:   []claim{{file: 0, match: "name", exact: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := winningClaims(tt.claims); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("winningClaims() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandTargets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "targets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configs := []string{
+		"repositories:\n  globs: [svc-*]\n",
+		"repository:\n  name: svc-billing\n",
+		"repositories:\n  topics: [go]\n",
+	}
+	var files []string
+	for i, c := range configs {
+		f := filepath.Join(dir, string('a'+rune(i))+".yaml")
+		if err := ioutil.WriteFile(f, []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	client := api.NewClient(api.ReplaceTripper(apitest.NewFakeGitHub(map[string]string{
+		"GET /orgs/o/repos": `[{"name":"svc-billing"},{"name":"svc-auth","topics":["go"]},
+			{"name":"svc-old","archived":true},{"name":"tools","topics":["go"]}]`,
+	})))
+
+	var got []string
+	for _, k := range expandTargets(client, "o", files, nil) {
+		s := filepath.Base(k.File) + " " + k.Repository + " " + k.Match
+		if k.Err != nil {
+			s += " claimed twice"
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"a.yaml svc-auth glob svc-* claimed twice",
+		"b.yaml svc-billing name",
+		"c.yaml tools topic go",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("targets\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,109 @@
+package command
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mkrakowitzer/ghsettings/config"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Check config files for mistakes without calling GitHub",
+	Long: `Check config files for mistakes without calling GitHub
+
+Reports unknown fields, values of the wrong type, permissions other than pull,
+triage, push, maintain or admin, negative review counts, duplicate users, teams
+and branches and strict status checks without status checks. The defaults file,
+the org config file and every config file are checked, or only the files given
+as arguments.
+
+Problems are printed as file:line:column: message. With --format github they
+are printed as GitHub Actions annotations instead. Exits non-zero if any file
+has a problem.`,
+	RunE: runValidate,
+}
+
+func init() {
+	validateCmd.Flags().String("format", "text", "Output format, text or github")
+	rootCmd.AddCommand(validateCmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "github" {
+		return fmt.Errorf("unknown format '%s', use text or github", format)
+	}
+	cmd.SilenceUsage = true
+
+	files := args
+	if len(files) == 0 {
+		var err error
+		files, err = configFiles()
+		if err != nil {
+			return err
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	problems, invalid := 0, 0
+	report := func(err error) {
+		invalid++
+		errs, ok := err.(config.Errors)
+		if !ok {
+			errs = config.Errors{{Message: err.Error()}}
+		}
+		for _, e := range errs {
+			problems++
+			printProblem(out, format, e)
+		}
+	}
+
+	var defaults []byte
+	if f := defaultsFile(); f != "" {
+		data, err := config.ReadDefaults(f)
+		if err != nil {
+			report(err)
+		}
+		defaults = data
+	}
+
+	for _, f := range files {
+		if _, err := config.Read(f, defaults); err != nil {
+			report(err)
+		}
+	}
+
+	checked := len(files)
+	if f := orgFile(); f != "" && len(args) == 0 {
+		checked++
+		if _, err := config.ReadOrg(f); err != nil {
+			report(err)
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("found %d problems in %d files", problems, invalid)
+	}
+	fmt.Fprintf(out, "%d config files are valid\n", checked)
+	return nil
+}
+
+// printProblem prints a problem as text or as a GitHub Actions annotation
+func printProblem(w io.Writer, format string, e *config.Error) {
+	if format != "github" {
+		fmt.Fprintln(w, e)
+		return
+	}
+	if e.File == "" {
+		fmt.Fprintf(w, "::error::%s\n", e.Message)
+		return
+	}
+	if e.Line == 0 {
+		fmt.Fprintf(w, "::error file=%s::%s\n", e.File, e.Message)
+		return
+	}
+	fmt.Fprintf(w, "::error file=%s,line=%d,col=%d::%s\n", e.File, e.Line, e.Column, e.Message)
+}
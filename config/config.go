@@ -1,41 +1,233 @@
 package config
 
+// C is the desired state of a single repository. Settings left out of the
+// YAML are nil and are not changed on GitHub.
+//
+// Besides yaml, fields carry the tags checked by Validate: enum lists the
+// allowed values, min the lowest allowed number and key the field that must
+// be unique within a list and format whether a string is a regexp, glob,
+// topic, color or secret or variable name.
+// desc describes the field in the JSON Schema.
 type C struct {
-	Repository struct {
-		Name                string `yaml:"name"`
-		Description         string `yaml:"description"`
-		Homepage            string `yaml:"homepage"`
-		Private             bool   `yaml:"private"`
-		HasIssues           bool   `yaml:"has_issues"`
-		HasProjects         bool   `yaml:"has_projects"`
-		HasWiki             bool   `yaml:"has_wiki"`
-		HasDownloads        bool   `yaml:"has_downloads"`
-		DefaultBranch       string `yaml:"default_branch"`
-		AllowSquashMerge    bool   `yaml:"allow_squash_merge"`
-		AllowMergeCommit    bool   `yaml:"allow_merge_commit"`
-		AllowRebaseMerge    bool   `yaml:"allow_rebase_merge"`
-		DeleteBranchOnMerge bool   `yaml:"delete_branch_on_merge"`
-	} `yaml:"repository"`
-	Collaborators []struct {
-		Username   string `yaml:"username"`
-		Permission string `yaml:"permission"`
-	} `yaml:"collaborators"`
-	Teams []struct {
-		Name       string `yaml:"name"`
-		Permission string `yaml:"permission"`
-	} `yaml:"teams"`
-	Branches []struct {
-		Name                         string   `yaml:"name"`
-		RequiredApprovingReviewCount int      `yaml:"requiredApprovingReviewCount"`
-		RequiresStatusChecks         bool     `yaml:"requiresStatusChecks"`
-		RequiredStatusCheckContexts  []string `yaml:"requiredStatusCheckContexts"`
-		RequiresApprovingReviews     bool     `yaml:"requiresApprovingReviews"`
-		RequiresCodeOwnerReviews     bool     `yaml:"requiresCodeOwnerReviews"`
-		RequiresCommitSignatures     bool     `yaml:"requiresCommitSignatures"`
-		RequiresStrictStatusChecks   bool     `yaml:"requiresStrictStatusChecks"`
-		RestrictsPushes              bool     `yaml:"restrictsPushes"`
-		IsAdminEnforced              bool     `yaml:"isAdminEnforced"`
-		DismissesStaleReviews        bool     `yaml:"dismissesStaleReviews"`
-		PushActorIds                 []string `yaml:"pushActorIds"`
-	} `yaml:"branches"`
+	Repository    Repository     `yaml:"repository" desc:"Settings of the repository itself"`
+	Collaborators []Collaborator `yaml:"collaborators" key:"username" desc:"Give specific users access to this repository"`
+	Teams         []Team         `yaml:"teams" key:"name" desc:"Give teams access to this repository"`
+	Branches      []Branch       `yaml:"branches" key:"name" desc:"Branch protection rules, one per branch name pattern"`
+	Labels        []Label        `yaml:"labels" key:"name" desc:"Issue and pull request labels"`
+	Webhooks      []Webhook      `yaml:"webhooks,omitempty" key:"url" desc:"Webhooks of the repository, matched by URL"`
+	DeployKeys    []DeployKey    `yaml:"deploy_keys" key:"title" desc:"Deploy keys of the repository, matched by key fingerprint"`
+	Secrets       []Secret       `yaml:"secrets,omitempty" key:"name" desc:"GitHub Actions secrets of the repository"`
+	Variables     []Variable     `yaml:"variables" key:"name" desc:"GitHub Actions configuration variables of the repository"`
+	Environments  []Environment  `yaml:"environments,omitempty" key:"name" desc:"Deployment environments of the repository and their protection rules"`
+	Rulesets      []Ruleset      `yaml:"rulesets,omitempty" key:"name" desc:"Repository rulesets for branches and tags, matched by name"`
+
+	// Repositories selects the repositories the file applies to when it is
+	// not for a single repository.name
+	Repositories *Selector `yaml:"repositories,omitempty" desc:"Apply this file to every repository matching any of these criteria instead of repository.name"`
+
+	// Merge sets the merge strategy of a list with the defaults, see Merge
+	Merge map[string]string `yaml:"merge,omitempty" enum:"keyed,append,replace" desc:"How the collaborators, teams, branches, labels, webhooks, deploy_keys, secrets, variables, environments and rulesets lists are merged with the defaults"`
+}
+
+type Repository struct {
+	Name                string  `yaml:"name" desc:"Repository name"`
+	Description         *string `yaml:"description,omitempty" desc:"A short description of the repository that will show up on GitHub"`
+	Homepage            *string `yaml:"homepage,omitempty" desc:"A URL with more information about the repository"`
+	Private             *bool   `yaml:"private,omitempty" desc:"Either true to make the repository private, or false to make it public"`
+	HasIssues           *bool   `yaml:"has_issues,omitempty" desc:"Either true to enable issues for this repository, false to disable them"`
+	HasProjects         *bool   `yaml:"has_projects,omitempty" desc:"Either true to enable projects for this repository, or false to disable them. If projects are disabled for the organization, passing true will cause an API error"`
+	HasWiki             *bool   `yaml:"has_wiki,omitempty" desc:"Either true to enable the wiki for this repository, false to disable it"`
+	HasDownloads        *bool   `yaml:"has_downloads,omitempty" desc:"Either true to enable downloads for this repository, false to disable them"`
+	DefaultBranch       *string `yaml:"default_branch,omitempty" desc:"Updates the default branch for this repository"`
+	AllowSquashMerge    *bool   `yaml:"allow_squash_merge,omitempty" desc:"Either true to allow squash-merging pull requests, or false to prevent squash-merging"`
+	AllowMergeCommit    *bool   `yaml:"allow_merge_commit,omitempty" desc:"Either true to allow merging pull requests with a merge commit, or false to prevent merging pull requests with merge commits"`
+	AllowRebaseMerge    *bool   `yaml:"allow_rebase_merge,omitempty" desc:"Either true to allow rebase-merging pull requests, or false to prevent rebase-merging"`
+	DeleteBranchOnMerge *bool   `yaml:"delete_branch_on_merge,omitempty" desc:"Delete head branches when pull requests are merged"`
+
+	// Topics are added to the live topics, or replace them with --enforce
+	Topics []string `yaml:"topics,omitempty" format:"topic" desc:"Topics of the repository, unlisted topics are removed with --enforce"`
+}
+
+// Selector picks repositories by name, pattern or topic. Archived
+// repositories and those matching Exclude are never picked.
+type Selector struct {
+	Names   []string `yaml:"names" desc:"Names of repositories"`
+	Globs   []string `yaml:"globs" format:"glob" desc:"Shell patterns matched against the repository name, e.g. svc-*"`
+	Regexes []string `yaml:"regexes" format:"regexp" desc:"Regular expressions matched against the whole repository name"`
+	Topics  []string `yaml:"topics" desc:"Topics, a repository with any of them is picked"`
+	Exclude []string `yaml:"exclude" format:"glob" desc:"Shell patterns of repository names that are never picked"`
+}
+
+type Collaborator struct {
+	Username   string `yaml:"username" desc:"GitHub login of the user"`
+	Permission string `yaml:"permission" enum:"pull,triage,push,maintain,admin" desc:"The permission to grant: pull, triage, push, maintain or admin"`
+}
+
+type Team struct {
+	Name       string `yaml:"name" desc:"Name or slug of the team"`
+	Permission string `yaml:"permission" enum:"pull,triage,push,maintain,admin" desc:"The permission to grant: pull, triage, push, maintain or admin"`
+}
+
+// Label is an issue and pull request label. OldName renames an existing label
+// instead of creating a new one.
+type Label struct {
+	Name        string  `yaml:"name" desc:"Name of the label"`
+	Color       *string `yaml:"color,omitempty" format:"color" desc:"Hexadecimal color code without the leading #, e.g. d73a4a"`
+	Description *string `yaml:"description,omitempty" desc:"A short description of the label"`
+	OldName     string  `yaml:"oldname,omitempty" desc:"Current name of a label to rename to name"`
+}
+
+// Webhook is a repository webhook. The secret is read from the environment
+// variable named by SecretEnv so it is never kept in the config.
+type Webhook struct {
+	URL         string   `yaml:"url" desc:"URL the payloads are delivered to"`
+	ContentType *string  `yaml:"content_type,omitempty" enum:"json,form" desc:"Media type used to serialize the payloads, json or form"`
+	Events      []string `yaml:"events" desc:"Events the hook is triggered for, default push. Use * for all events"`
+	Active      *bool    `yaml:"active,omitempty" desc:"Whether notifications are sent when the hook is triggered"`
+	InsecureSSL *bool    `yaml:"insecure_ssl,omitempty" desc:"Skip verifying the SSL certificate of the URL. Not recommended"`
+	SecretEnv   string   `yaml:"secret_env,omitempty" desc:"Name of the environment variable holding the secret used to sign payloads"`
+}
+
+// DeployKey is an SSH key with access to a single repository. Either Key or
+// KeyFile is set; Read loads KeyFile into Key.
+type DeployKey struct {
+	Title    string `yaml:"title" desc:"Name of the key"`
+	Key      string `yaml:"key,omitempty" desc:"Public key, e.g. ssh-ed25519 AAAA..."`
+	KeyFile  string `yaml:"key_file,omitempty" desc:"Path to a .pub file holding the public key, relative to the working directory"`
+	ReadOnly *bool  `yaml:"read_only,omitempty" desc:"Whether the key can only read, not write to the repository"`
+}
+
+// Secret is a GitHub Actions secret. Its value is read from exactly one of
+// an environment variable, a file or the output of a command, never from the
+// config itself.
+type Secret struct {
+	Name    string `yaml:"name" format:"secret" desc:"Name of the secret"`
+	Env     string `yaml:"env,omitempty" desc:"Environment variable holding the value"`
+	File    string `yaml:"file,omitempty" desc:"File holding the value, relative to the working directory"`
+	Command string `yaml:"command,omitempty" desc:"Shell command printing the value. GHSETTINGS_REPOSITORY and GHSETTINGS_SECRET are set to the repository and secret name"`
+}
+
+// Variable is a GitHub Actions configuration variable. Unlike a secret its
+// value is kept in the config and shown in plans.
+type Variable struct {
+	Name  string `yaml:"name" format:"secret" desc:"Name of the variable, available to workflows as vars.NAME"`
+	Value string `yaml:"value" desc:"Value of the variable"`
+}
+
+// Environment is a deployment environment. Settings left out are not
+// changed, secrets and variables are handled like those of the repository.
+type Environment struct {
+	Name               string               `yaml:"name" desc:"Name of the environment"`
+	WaitTimer          *int                 `yaml:"wait_timer,omitempty" min:"0" desc:"Minutes to wait before a job referencing the environment starts, at most 43200"`
+	Reviewers          *EnvironmentReviewer `yaml:"reviewers,omitempty" desc:"Users and teams that must approve jobs referencing the environment, at most 6"`
+	DeploymentBranches *string              `yaml:"deployment_branches,omitempty" enum:"all,protected,custom" desc:"Branches that can deploy: all, protected or custom to allow only the branch_patterns"`
+	BranchPatterns     []string             `yaml:"branch_patterns,omitempty" desc:"Name patterns of the branches that can deploy when deployment_branches is custom, e.g. release/*"`
+	Secrets            []Secret             `yaml:"secrets,omitempty" key:"name" desc:"GitHub Actions secrets of the environment"`
+	Variables          []Variable           `yaml:"variables" key:"name" desc:"GitHub Actions configuration variables of the environment"`
+}
+
+// EnvironmentReviewer lists the required reviewers of an environment by
+// login and team name. They are resolved to IDs when the environment is
+// updated.
+type EnvironmentReviewer struct {
+	Users []string `yaml:"users" desc:"Logins of the users, an empty list clears them"`
+	Teams []string `yaml:"teams" desc:"Names or slugs of the teams, an empty list clears them"`
+}
+
+// Ruleset is a repository ruleset. Unlike a branch protection rule it can
+// target tags, exclude refs and let some actors bypass it. Settings left out
+// are not changed.
+type Ruleset struct {
+	Name         string        `yaml:"name" desc:"Name of the ruleset"`
+	Target       *string       `yaml:"target,omitempty" enum:"branch,tag" desc:"Whether the ruleset applies to branches or tags. New rulesets default to branch"`
+	Enforcement  *string       `yaml:"enforcement,omitempty" enum:"active,evaluate,disabled" desc:"active enforces the rules, evaluate only reports them and disabled turns them off. New rulesets default to active"`
+	Include      []string      `yaml:"include" desc:"Patterns of the branches or tags the ruleset applies to, e.g. main or release/*. ~DEFAULT_BRANCH and ~ALL are special"`
+	Exclude      []string      `yaml:"exclude" desc:"Patterns of the branches or tags excluded from the ruleset"`
+	BypassActors []BypassActor `yaml:"bypass_actors,omitempty" desc:"Teams, roles, apps and deploy keys that can bypass the rules, an empty list clears them"`
+	Rules        *Rules        `yaml:"rules,omitempty" desc:"Rules enforced on matching branches or tags"`
+}
+
+// BypassActor is allowed to bypass a ruleset. Exactly one of Team, Role,
+// AppID and DeployKeys is set.
+type BypassActor struct {
+	Team       string  `yaml:"team,omitempty" desc:"Name or slug of a team"`
+	Role       *string `yaml:"role,omitempty" enum:"maintain,write,admin,org_admin" desc:"Repository role maintain, write or admin, or org_admin for organisation owners"`
+	AppID      *int    `yaml:"app_id,omitempty" min:"1" desc:"ID of a GitHub App"`
+	DeployKeys *bool   `yaml:"deploy_keys,omitempty" desc:"Set to true for the deploy keys of the repository"`
+	Mode       *string `yaml:"mode,omitempty" enum:"always,pull_request" desc:"Bypass always or only when merging pull requests, default always"`
+}
+
+// Rules are the rules of a ruleset. A rule left out is not changed, or
+// removed with --enforce; a rule set to false is removed.
+type Rules struct {
+	PullRequest           *PullRequestRule  `yaml:"pull_request,omitempty" desc:"Require a pull request before merging"`
+	RequiredStatusChecks  *StatusChecksRule `yaml:"required_status_checks,omitempty" desc:"Require status checks to pass before merging"`
+	RequiredSignatures    *bool             `yaml:"required_signatures,omitempty" desc:"Commits pushed to matching refs must have verified signatures"`
+	NonFastForward        *bool             `yaml:"non_fast_forward,omitempty" desc:"Prevent force pushes"`
+	Deletion              *bool             `yaml:"deletion,omitempty" desc:"Prevent deleting matching refs"`
+	RequiredLinearHistory *bool             `yaml:"required_linear_history,omitempty" desc:"Prevent merge commits from being pushed"`
+}
+
+// PullRequestRule holds the parameters of the pull_request rule, named as
+// in the REST API. Parameters left out keep their value, or default to
+// false and 0 when the rule is added.
+type PullRequestRule struct {
+	RequiredApprovingReviewCount   *int  `yaml:"required_approving_review_count,omitempty" min:"0" desc:"Required number of approving reviews"`
+	DismissStaleReviewsOnPush      *bool `yaml:"dismiss_stale_reviews_on_push,omitempty" desc:"Dismiss approvals when new commits are pushed"`
+	RequireCodeOwnerReview         *bool `yaml:"require_code_owner_review,omitempty" desc:"Require an approving review from a code owner of the changed files"`
+	RequireLastPushApproval        *bool `yaml:"require_last_push_approval,omitempty" desc:"The last push must be approved by someone other than its author"`
+	RequiredReviewThreadResolution *bool `yaml:"required_review_thread_resolution,omitempty" desc:"All review threads must be resolved before merging"`
+}
+
+// StatusChecksRule holds the parameters of the required_status_checks rule
+type StatusChecksRule struct {
+	Strict   *bool    `yaml:"strict,omitempty" desc:"Require branches to be up to date before merging"`
+	Contexts []string `yaml:"contexts" desc:"Names of the status checks that must pass"`
+}
+
+// Org is the desired state of the organisation itself, read from the org
+// config file rather than from the repository configs
+type Org struct {
+	Variables []OrgVariable `yaml:"variables" key:"name" desc:"GitHub Actions configuration variables of the organisation"`
+}
+
+// OrgVariable is an organisation Actions variable. Repositories lists the
+// repositories that can use it when Visibility is selected.
+type OrgVariable struct {
+	Name         string   `yaml:"name" format:"secret" desc:"Name of the variable, available to workflows as vars.NAME"`
+	Value        string   `yaml:"value" desc:"Value of the variable"`
+	Visibility   *string  `yaml:"visibility,omitempty" enum:"all,private,selected" desc:"Repositories that can use the variable: all, private or selected. New variables default to private"`
+	Repositories []string `yaml:"repositories" desc:"Names of the repositories that can use the variable when visibility is selected"`
+}
+
+// Branch is a branch protection rule. A nil list is left untouched, while an
+// empty list clears it.
+type Branch struct {
+	Name                         string   `yaml:"name" desc:"Branch name pattern the rule applies to"`
+	RequiredApprovingReviewCount *int     `yaml:"requiredApprovingReviewCount,omitempty" min:"0" desc:"Required number of approvers"`
+	RequiresStatusChecks         *bool    `yaml:"requiresStatusChecks,omitempty" desc:"Require status checks to pass before merging"`
+	RequiredStatusCheckContexts  []string `yaml:"requiredStatusCheckContexts" desc:"The names of the status checks that must pass, an empty list clears them"`
+	RequiresApprovingReviews     *bool    `yaml:"requiresApprovingReviews,omitempty" desc:"Require pull request reviews before merging"`
+	RequiresCodeOwnerReviews     *bool    `yaml:"requiresCodeOwnerReviews,omitempty" desc:"Require an approved review in pull requests including files with a designated code owner"`
+	RequiresCommitSignatures     *bool    `yaml:"requiresCommitSignatures,omitempty" desc:"Commits pushed to matching branches must have verified signatures"`
+	RequiresStrictStatusChecks   *bool    `yaml:"requiresStrictStatusChecks,omitempty" desc:"Require branches to be up to date before merging. Needs requiresStatusChecks and at least one status check"`
+	RestrictsPushes              *bool    `yaml:"restrictsPushes,omitempty" desc:"Restrict who can push to matching branches"`
+	IsAdminEnforced              *bool    `yaml:"isAdminEnforced,omitempty" desc:"Enforce all configured restrictions for administrators"`
+	DismissesStaleReviews        *bool    `yaml:"dismissesStaleReviews,omitempty" desc:"Dismiss stale pull request approvals when new commits are pushed"`
+	PushActorIds                 []string `yaml:"pushActorIds" desc:"Node IDs of the people, teams or apps allowed to push to matching branches, an empty list clears them"`
+}
+
+// Bool returns a pointer to v for setting optional fields
+func Bool(v bool) *bool {
+	return &v
+}
+
+// String returns a pointer to v for setting optional fields
+func String(v string) *string {
+	return &v
+}
+
+// Int returns a pointer to v for setting optional fields
+func Int(v int) *int {
+	return &v
 }
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Strategies for merging a list in a repository config with the defaults
+const (
+	MergeKeyed   = "keyed"
+	MergeAppend  = "append"
+	MergeReplace = "replace"
+)
+
+// keyedLists are the top level lists merged by key, and the key of each
+var keyedLists = map[string]string{
+	"collaborators": "username",
+	"teams":         "name",
+	"branches":      "name",
+	"labels":        "name",
+	"webhooks":      "url",
+	"deploy_keys":   "title",
+	"secrets":       "name",
+	"variables":     "name",
+	"environments":  "name",
+	"rulesets":      "name",
+}
+
+// Read parses a repository config file. When defaults is not empty the file
+// is merged over it first, see Merge. The file is checked with Validate and
+// decoded strictly, so unknown fields are an error.
+func Read(file string, defaults []byte) (C, error) {
+
+	var c C
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return c, err
+	}
+	root, errs := validate(file, data)
+	if len(errs) > 0 {
+		return c, errs
+	}
+	if len(defaults) > 0 {
+		data, err = Merge(defaults, data)
+		if err != nil {
+			return c, fmt.Errorf("%s: %s", file, err)
+		}
+	}
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %s", file, err)
+	}
+	errs = append(checkTargets(file, root, c), checkConfig(file, root, &c)...)
+	if len(errs) > 0 {
+		return c, errs
+	}
+	return c, nil
+}
+
+// ReadDefaults reads the defaults file and checks it like Read checks a
+// repository config, except that it names no repository. Problems are
+// reported against the defaults file, rather than against every repository
+// config it is merged under.
+func ReadDefaults(file string) ([]byte, error) {
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	root, errs := validate(file, data)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	var c C
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	if errs := checkConfig(file, root, &c); len(errs) > 0 {
+		return nil, errs
+	}
+	return data, nil
+}
+
+// checkConfig runs the checks that need the decoded config and loads the
+// deploy key files
+func checkConfig(file string, root *yamlv3.Node, c *C) Errors {
+	errs := checkBranches(file, root, *c)
+	errs = append(errs, loadDeployKeys(file, root, c)...)
+	errs = append(errs, checkSecrets(file, root, *c)...)
+	errs = append(errs, checkEnvironments(file, root, *c)...)
+	errs = append(errs, checkRulesets(file, root, *c)...)
+	return errs
+}
+
+// Merge deep merges a repository config over the defaults and returns the
+// result as YAML. Maps are merged recursively and repository values win.
+//
+// The collaborators list is merged by username, the webhooks list by url,
+// the deploy_keys list by title and the teams, branches, labels, secrets,
+// variables, environments and rulesets lists by name: an entry present in
+// both, comparing keys ignoring case, is merged like a map, and entries
+// present in only one are kept, defaults first. A repository can change
+// this per list with the merge key:
+//
+//	merge:
+//	  collaborators: replace  # drop the default collaborators
+//	  teams: append           # add to the default teams without merging
+//
+// Any other list in the repository config replaces the one in the defaults.
+func Merge(defaults, repo []byte) ([]byte, error) {
+
+	base := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(defaults, &base); err != nil {
+		return nil, fmt.Errorf("defaults: %s", err)
+	}
+	over := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(repo, &over); err != nil {
+		return nil, err
+	}
+
+	strategies := map[string]string{}
+	if m, ok := over["merge"].(map[interface{}]interface{}); ok {
+		for k, v := range m {
+			strategies[fmt.Sprint(k)] = fmt.Sprint(v)
+		}
+	}
+	delete(base, "merge")
+	delete(over, "merge")
+
+	merged := mergeMaps(base, over)
+	for name, key := range keyedLists {
+		_, inBase := base[name]
+		_, inOver := over[name]
+		if !inBase && !inOver {
+			// an empty list would remove everything with --enforce
+			continue
+		}
+		strategy, ok := strategies[name]
+		if !ok {
+			strategy = MergeKeyed
+		}
+		b, _ := base[name].([]interface{})
+		o, _ := over[name].([]interface{})
+
+		switch strategy {
+		case MergeKeyed:
+			merged[name] = mergeLists(b, o, key)
+		case MergeAppend:
+			merged[name] = append(append([]interface{}{}, b...), o...)
+		case MergeReplace:
+			merged[name] = o
+		default:
+			return nil, fmt.Errorf("unknown merge strategy '%s' for %s", strategy, name)
+		}
+	}
+
+	return yaml.Marshal(merged)
+}
+
+// mergedLists returns the names of the lists merged by key, sorted
+func mergedLists() []string {
+	names := make([]string, 0, len(keyedLists))
+	for name := range keyedLists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func mergeMaps(base, over map[interface{}]interface{}) map[interface{}]interface{} {
+	merged := make(map[interface{}]interface{}, len(base)+len(over))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range over {
+		bm, bok := merged[k].(map[interface{}]interface{})
+		om, ook := v.(map[interface{}]interface{})
+		if bok && ook {
+			merged[k] = mergeMaps(bm, om)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func mergeLists(base, over []interface{}, key string) []interface{} {
+	merged := []interface{}{}
+	used := map[int]bool{}
+	for _, b := range base {
+		bm, ok := b.(map[interface{}]interface{})
+		if !ok {
+			merged = append(merged, b)
+			continue
+		}
+		for i, o := range over {
+			om, ok := o.(map[interface{}]interface{})
+			if ok && !used[i] && sameKey(om[key], bm[key]) {
+				bm = mergeMaps(bm, om)
+				used[i] = true
+			}
+		}
+		merged = append(merged, bm)
+	}
+	for i, o := range over {
+		if !used[i] {
+			merged = append(merged, o)
+		}
+	}
+	return merged
+}
+
+// sameKey compares the keys of two list entries ignoring case, like Validate
+// does when it looks for duplicates
+func sameKey(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return strings.EqualFold(fmt.Sprint(a), fmt.Sprint(b))
+}
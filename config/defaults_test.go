@@ -0,0 +1,190 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestMerge(t *testing.T) {
+	defaults := `
+repository:
+  private: true
+  has_wiki: false
+teams:
+  - name: platform
+    permission: push
+  - name: security
+    permission: pull
+labels:
+  - name: bug
+    color: d73a4a
+`
+	tests := []struct {
+		name string
+		repo string
+		want string
+	}{
+		{
+			name: "maps are merged and repository values win",
+			repo: "repository:\n  name: r\n  has_wiki: true\n",
+			want: `
+repository: {name: r, private: true, has_wiki: true}
+teams: [{name: platform, permission: push}, {name: security, permission: pull}]
+labels: [{name: bug, color: d73a4a}]
+`,
+		},
+		{
+			name: "keyed lists merge entries by key, ignoring case",
+			repo: `
+teams:
+  - name: Security
+    permission: admin
+  - name: docs
+    permission: triage
+`,
+			want: `
+repository: {private: true, has_wiki: false}
+teams: [{name: platform, permission: push}, {name: Security, permission: admin}, {name: docs, permission: triage}]
+labels: [{name: bug, color: d73a4a}]
+`,
+		},
+		{
+			name: "append keeps duplicates",
+			repo: `
+merge: {teams: append}
+teams:
+  - name: security
+    permission: admin
+`,
+			want: `
+repository: {private: true, has_wiki: false}
+teams: [{name: platform, permission: push}, {name: security, permission: pull}, {name: security, permission: admin}]
+labels: [{name: bug, color: d73a4a}]
+`,
+		},
+		{
+			name: "replace drops the defaults",
+			repo: `
+merge: {labels: replace}
+labels:
+  - name: wontfix
+`,
+			want: `
+repository: {private: true, has_wiki: false}
+teams: [{name: platform, permission: push}, {name: security, permission: pull}]
+labels: [{name: wontfix}]
+`,
+		},
+		{
+			name: "replace without a list drops the defaults",
+			repo: "merge: {teams: replace}\n",
+			want: `
+repository: {private: true, has_wiki: false}
+teams: []
+labels: [{name: bug, color: d73a4a}]
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Merge([]byte(defaults), []byte(tt.repo))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want map[interface{}]interface{}
+			if err := yaml.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("merged\n%s\nwant\n%s", data, tt.want)
+			}
+		})
+	}
+}
+
+// TestMergeUnsetLists checks that lists neither file sets stay unset, so
+// that --enforce leaves those settings of the repository alone
+func TestMergeUnsetLists(t *testing.T) {
+	data, err := Merge([]byte("repository: {private: true}\n"), []byte("repository: {name: r}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c C
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Labels != nil || c.Webhooks != nil || c.Teams != nil || c.Rulesets != nil {
+		t.Errorf("unset lists are managed after merging:\n%s", data)
+	}
+}
+
+func TestMergeUnknownStrategy(t *testing.T) {
+	_, err := Merge([]byte("teams: []\n"), []byte("merge: {teams: union}\n"))
+	if err == nil {
+		t.Error("unknown merge strategy accepted")
+	}
+}
+
+// TestReadDefaults checks that problems in the defaults file are reported
+// against it, and only once, before any repository file is read
+func TestReadDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "defaults.yaml")
+
+	tests := []struct {
+		name     string
+		defaults string
+		errs     []string
+	}{
+		{
+			name:     "valid",
+			defaults: "repository: {private: true}\nlabels: [{name: bug}]\n",
+		},
+		{
+			name:     "misspelt field",
+			defaults: "repository:\n  privat: true\n",
+			errs:     []string{":2:3: unknown field repository.privat, did you mean private?"},
+		},
+		{
+			name:     "strict without status checks",
+			defaults: "branches:\n  - name: main\n    requiresStrictStatusChecks: true\n",
+			errs:     []string{":3:33: branches: main sets requiresStrictStatusChecks without status checks"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(file, []byte(tt.defaults), 0644); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ReadDefaults(file)
+			var got []string
+			if err != nil {
+				got = strings.Split(err.Error(), "\n")
+			}
+			if len(got) != len(tt.errs) {
+				t.Fatalf("errors\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.errs, "\n"))
+			}
+			for j := range got {
+				if !strings.HasPrefix(got[j], file+tt.errs[j]) {
+					t.Errorf("error %s, want %s%s", got[j], file, tt.errs[j])
+				}
+			}
+			if err == nil && string(data) != tt.defaults {
+				t.Errorf("read %q, want %q", data, tt.defaults)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// KeyFingerprint returns the SHA256 fingerprint of an OpenSSH public key as
+// shown by ssh-keygen -l and GitHub, e.g. SHA256:nThbg6kXUpJW...
+func KeyFingerprint(key string) (string, error) {
+	fields := strings.Fields(key)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("not an OpenSSH public key, expected a type and base64 key")
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", fmt.Errorf("not an OpenSSH public key: %s", err)
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// loadDeployKeys reads the key_file of every deploy key into its key and
+// checks that each key is a public key
+func loadDeployKeys(file string, root *yamlv3.Node, c *C) Errors {
+	var errs Errors
+	for i := range c.DeployKeys {
+		k := &c.DeployKeys[i]
+		fail := func(format string, a ...interface{}) {
+			e := &Error{File: file, Message: fmt.Sprintf("deploy_keys: %s: ", k.Title) + fmt.Sprintf(format, a...)}
+			if n := listItem(root, "deploy_keys", "title", k.Title); n != nil {
+				e.Line, e.Column = n.Line, n.Column
+			}
+			errs = append(errs, e)
+		}
+
+		switch {
+		case k.Key != "" && k.KeyFile != "":
+			fail("set either key or key_file, not both")
+			continue
+		case k.KeyFile != "":
+			data, err := ioutil.ReadFile(k.KeyFile)
+			if err != nil {
+				fail("%s", err)
+				continue
+			}
+			k.Key = strings.TrimSpace(string(data))
+		case k.Key == "":
+			fail("key or key_file must be set")
+			continue
+		}
+		if strings.Contains(k.Key, "PRIVATE KEY") {
+			fail("this is a private key, use the public key")
+			continue
+		}
+		if _, err := KeyFingerprint(k.Key); err != nil {
+			fail("%s", err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// ReadOrg parses the org config file. It is checked like a repository
+// config but is never merged with the defaults.
+func ReadOrg(file string) (Org, error) {
+
+	var o Org
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return o, err
+	}
+	root, v := validateAs(file, data, reflect.TypeOf(Org{}))
+	if len(v.errs) > 0 {
+		return o, v.errs
+	}
+	if err := yaml.UnmarshalStrict(data, &o); err != nil {
+		return o, fmt.Errorf("%s: %s", file, err)
+	}
+	if errs := checkOrgVariables(file, root, o); len(errs) > 0 {
+		return o, errs
+	}
+	return o, nil
+}
+
+// checkOrgVariables reports repositories listed for a variable whose
+// visibility is not selected
+func checkOrgVariables(file string, root *yamlv3.Node, o Org) Errors {
+	var errs Errors
+	for _, ov := range o.Variables {
+		if len(ov.Repositories) == 0 || (ov.Visibility != nil && *ov.Visibility == "selected") {
+			continue
+		}
+		e := &Error{File: file, Message: fmt.Sprintf("variables: %s lists repositories, set visibility: selected", ov.Name)}
+		if item := listItem(root, "variables", "name", ov.Name); item != nil {
+			if n := mappingValue(item, "repositories"); n != nil {
+				item = n
+			}
+			e.Line, e.Column = item.Line, item.Column
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SchemaID is the $id of the JSON Schema returned by Schema
+const SchemaID = "https://github.com/mkrakowitzer/ghsettings/config.schema.json"
+
+// Schema returns a JSON Schema of the repository config file, generated from
+// C and the enum, min, key and desc tags of its fields. Editors using
+// yaml-language-server can use it to complete and check config files.
+func Schema() ([]byte, error) {
+	s := schemaFor(reflect.TypeOf(C{}), "")
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["$id"] = SchemaID
+	s["title"] = "ghsettings repository config"
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// schemaFor returns the schema of type t for a field with the given tag.
+// Pointers, lists and maps may be null like Validate allows, which leaves
+// the setting unset.
+func schemaFor(t reflect.Type, tag reflect.StructTag) map[string]interface{} {
+	nullable := t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := map[string]interface{}{}
+	if desc, ok := tag.Lookup("desc"); ok {
+		s["description"] = desc
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			props[yamlName(f)] = schemaFor(f.Type, f.Tag)
+		}
+		s["type"] = "object"
+		s["properties"] = props
+		s["additionalProperties"] = false
+
+	case reflect.Map:
+		s["type"] = "object"
+		s["propertyNames"] = map[string]interface{}{"enum": mergedLists()}
+		s["additionalProperties"] = schemaFor(t.Elem(), reflect.StructTag(`enum:"`+tag.Get("enum")+`"`))
+
+	case reflect.Slice:
+		items := schemaFor(t.Elem(), itemTag(tag))
+		if key := tag.Get("key"); key != "" {
+			items["required"] = []string{key}
+		}
+		s["type"] = "array"
+		s["items"] = items
+
+	case reflect.Bool:
+		s["type"] = "boolean"
+
+	case reflect.Int:
+		s["type"] = "integer"
+		if min, ok := tag.Lookup("min"); ok {
+			n, _ := strconv.Atoi(min)
+			s["minimum"] = n
+		}
+
+	case reflect.String:
+		s["type"] = "string"
+		if enum, ok := tag.Lookup("enum"); ok {
+			s["enum"] = strings.Split(enum, ",")
+		}
+		switch tag.Get("format") {
+		case "regexp":
+			s["format"] = "regex"
+		case "topic":
+			s["pattern"] = topicPattern.String()
+		case "color":
+			s["pattern"] = colorPattern.String()
+		case "secret":
+			s["pattern"] = secretPattern.String()
+			s["not"] = map[string]interface{}{"pattern": reservedSecretPrefix}
+		}
+	}
+
+	if nullable {
+		s["type"] = []interface{}{s["type"], "null"}
+		if enum, ok := s["enum"].([]string); ok {
+			values := []interface{}{}
+			for _, e := range enum {
+				values = append(values, e)
+			}
+			s["enum"] = append(values, nil)
+		}
+	}
+	return s
+}
+
+// reservedSecretPrefix matches the GITHUB_ prefix, in any case, that the
+// names of secrets and variables may not start with
+const reservedSecretPrefix = "^[Gg][Ii][Tt][Hh][Uu][Bb]_"
+
+// itemTag returns the parts of the tag of a list field that apply to each item
+func itemTag(tag reflect.StructTag) reflect.StructTag {
+	var parts []string
+	for _, name := range []string{"enum", "min", "format"} {
+		if v, ok := tag.Lookup(name); ok {
+			parts = append(parts, fmt.Sprintf("%s:%q", name, v))
+		}
+	}
+	return reflect.StructTag(strings.Join(parts, " "))
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// checkSchema reports where v does not match schema. It understands only the
+// keywords Schema generates.
+func checkSchema(schema map[string]interface{}, v interface{}, at string) []string {
+	var errs []string
+	if types, ok := schema["type"]; ok && !hasType(types, v) {
+		return []string{fmt.Sprintf("%s: %v is not of type %v", at, v, types)}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", at, v, enum))
+		}
+	}
+	if min, ok := schema["minimum"].(float64); ok {
+		if n, ok := v.(float64); ok && n < min {
+			errs = append(errs, fmt.Sprintf("%s: %v is less than %v", at, n, min))
+		}
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if s, ok := v.(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			errs = append(errs, fmt.Sprintf("%s: %s does not match %s", at, s, pattern))
+		}
+	}
+	if not, ok := schema["not"].(map[string]interface{}); ok && len(checkSchema(not, v, at)) == 0 {
+		errs = append(errs, fmt.Sprintf("%s: %v matches %v", at, v, not))
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, r := range schemaStrings(schema["required"]) {
+			if _, ok := v[r]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: %s is required", at, r))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		props, _ := schema["properties"].(map[string]interface{})
+		for _, k := range keys {
+			if names, ok := schema["propertyNames"].(map[string]interface{}); ok {
+				errs = append(errs, checkSchema(names, k, at+"."+k)...)
+			}
+			switch p := props[k].(type) {
+			case map[string]interface{}:
+				errs = append(errs, checkSchema(p, v[k], at+"."+k)...)
+				continue
+			}
+			switch a := schema["additionalProperties"].(type) {
+			case bool:
+				if !a {
+					errs = append(errs, fmt.Sprintf("%s: unknown property %s", at, k))
+				}
+			case map[string]interface{}:
+				errs = append(errs, checkSchema(a, v[k], at+"."+k)...)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, checkSchema(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+// hasType reports whether v, decoded from JSON, is of one of the types
+func hasType(types interface{}, v interface{}) bool {
+	for _, t := range schemaStrings(types) {
+		switch v := v.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// schemaStrings returns a string, or a list of strings, as a list
+func schemaStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var list []string
+		for _, s := range v {
+			list = append(list, fmt.Sprint(s))
+		}
+		return list
+	}
+	return nil
+}
+
+// yamlAsJSON decodes a YAML document into the values its JSON form decodes to
+func yamlAsJSON(t *testing.T, data []byte) interface{} {
+	var v interface{}
+	if err := yamlv3.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(j, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestSchema(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	example, err := ioutil.ReadFile("../examples/example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := checkSchema(schema, yamlAsJSON(t, example), "example.yaml"); len(errs) > 0 {
+		t.Errorf("examples/example.yaml does not match the schema:\n%s", strings.Join(errs, "\n"))
+	}
+
+	tests := []struct {
+		config string
+		valid  bool
+	}{
+		{"repository: {name: r, private: null}\nlabels: null\n", true},
+		{"environments: [{name: production, deployment_branches: null, reviewers: null}]", true},
+		{"repository: {name: null}", false},
+		{"secrets: [{name: NPM_TOKEN, env: NPM_TOKEN}]", true},
+		{"secrets: [{name: GITHUB_TOKEN, env: TOKEN}]", false},
+		{"variables: [{name: github_region, value: eu}]", false},
+		{"variables: [{name: 1REGION, value: eu}]", false},
+		{"collaborators: [{username: dev, permission: maintian}]", false},
+	}
+	for _, tt := range tests {
+		errs := checkSchema(schema, yamlAsJSON(t, []byte(tt.config)), "")
+		if valid := len(errs) == 0; valid != tt.valid {
+			t.Errorf("schema accepts %q: %v, want %v %v", tt.config, valid, tt.valid, errs)
+		}
+	}
+}
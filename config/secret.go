@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Value reads the value of the secret from its source. The output of a
+// command has its trailing newline removed, a file is used as is.
+func (s Secret) Value(repository string) (string, error) {
+	switch {
+	case s.Env != "":
+		v, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("secret %s: environment variable %s is not set", s.Name, s.Env)
+		}
+		return v, nil
+	case s.File != "":
+		data, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("secret %s: %s", s.Name, err)
+		}
+		return string(data), nil
+	case s.Command != "":
+		cmd := exec.Command("sh", "-c", s.Command)
+		cmd.Env = append(os.Environ(), "GHSETTINGS_REPOSITORY="+repository, "GHSETTINGS_SECRET="+s.Name)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("secret %s: command '%s': %s", s.Name, s.Command, err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+	return "", fmt.Errorf("secret %s has no env, file or command", s.Name)
+}
+
+// checkSecrets reports secrets of the repository or an environment without
+// exactly one source
+func checkSecrets(file string, root *yamlv3.Node, c C) Errors {
+	errs := checkSecretSources(file, root, "secrets", c.Secrets)
+	for _, env := range c.Environments {
+		errs = append(errs, checkSecretSources(file, listItem(root, "environments", "name", env.Name), "environments: "+env.Name+": secrets", env.Secrets)...)
+	}
+	return errs
+}
+
+// checkSecretSources checks the secrets list of node, which is nil when the
+// list comes from the defaults
+func checkSecretSources(file string, node *yamlv3.Node, field string, secrets []Secret) Errors {
+	var errs Errors
+	for _, s := range secrets {
+		n := 0
+		for _, source := range []string{s.Env, s.File, s.Command} {
+			if source != "" {
+				n++
+			}
+		}
+		if n == 1 {
+			continue
+		}
+		e := &Error{File: file, Message: fmt.Sprintf("%s: %s must set exactly one of env, file and command", field, s.Name)}
+		if item := listItem(node, "secrets", "name", s.Name); item != nil {
+			e.Line, e.Column = item.Line, item.Column
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
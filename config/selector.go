@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Named reports whether the selector lists name in Names
+func (s *Selector) Named(name string) bool {
+	for _, n := range s.Names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the selector picks the repository with the given
+// name and topics and describes the criterion that picked it
+func (s *Selector) Match(name string, topics []string) (string, bool) {
+	lower := strings.ToLower(name)
+	for _, g := range s.Exclude {
+		if ok, _ := path.Match(strings.ToLower(g), lower); ok {
+			return "", false
+		}
+	}
+
+	if s.Named(name) {
+		return "name", true
+	}
+	for _, g := range s.Globs {
+		if ok, _ := path.Match(strings.ToLower(g), lower); ok {
+			return fmt.Sprintf("glob %s", g), true
+		}
+	}
+	for _, r := range s.Regexes {
+		re, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", r))
+		if err == nil && re.MatchString(name) {
+			return fmt.Sprintf("regex %s", r), true
+		}
+	}
+	for _, t := range s.Topics {
+		for _, topic := range topics {
+			if strings.EqualFold(t, topic) {
+				return fmt.Sprintf("topic %s", t), true
+			}
+		}
+	}
+	return "", false
+}
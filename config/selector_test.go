@@ -0,0 +1,36 @@
+package config
+
+import "testing"
+
+func TestSelectorMatch(t *testing.T) {
+	s := &Selector{
+		Names:   []string{"Legacy"},
+		Globs:   []string{"svc-*"},
+		Regexes: []string{`lib-[a-z]+`},
+		Topics:  []string{"Terraform"},
+		Exclude: []string{"*-archive", "svc-internal"},
+	}
+	tests := []struct {
+		name   string
+		topics []string
+		match  string
+		ok     bool
+	}{
+		{"legacy", nil, "name", true},
+		{"svc-billing", nil, "glob svc-*", true},
+		{"SVC-Billing", nil, "glob svc-*", true},
+		{"lib-http", nil, "regex lib-[a-z]+", true},
+		{"lib-http2", nil, "", false},
+		{"my-lib-http", nil, "", false},
+		{"infra", []string{"go", "terraform"}, "topic Terraform", true},
+		{"infra", []string{"go"}, "", false},
+		{"svc-internal", nil, "", false},
+		{"legacy-archive", nil, "", false},
+	}
+	for _, tt := range tests {
+		match, ok := s.Match(tt.name, tt.topics)
+		if match != tt.match || ok != tt.ok {
+			t.Errorf("Match(%q, %v) = %q, %v, want %q, %v", tt.name, tt.topics, match, ok, tt.match, tt.ok)
+		}
+	}
+}
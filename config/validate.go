@@ -0,0 +1,441 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Error is a problem found in a config file. Line and Column are zero when
+// the problem can not be pinned to a position, e.g. when it comes from the
+// defaults.
+type Error struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.File == "" {
+		return e.Message
+	}
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	if e.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// Errors holds every problem found in a config file
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Validate checks a single config file, before it is merged with the
+// defaults. It reports unknown fields, values of the wrong type, values not
+// allowed by the enum and min tags of C and duplicate entries in lists with a
+// key tag. The returned error is of type Errors.
+func Validate(file string, data []byte) error {
+	_, errs := validate(file, data)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validate(file string, data []byte) (*yamlv3.Node, Errors) {
+	root, v := validateAs(file, data, reflect.TypeOf(C{}))
+	if merge := mappingValue(root, "merge"); merge != nil && merge.Kind == yamlv3.MappingNode {
+		for i := 0; i < len(merge.Content); i += 2 {
+			if _, ok := keyedLists[merge.Content[i].Value]; !ok {
+				v.errorf(merge.Content[i], "merge: %s can not be merged, only %s", merge.Content[i].Value, strings.Join(mergedLists(), ", "))
+			}
+		}
+	}
+	return root, v.errs
+}
+
+// validateAs parses data and checks it against the config type t
+func validateAs(file string, data []byte, t reflect.Type) (*yamlv3.Node, *validator) {
+	v := &validator{file: file}
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		line, msg := errorLine(err)
+		v.errs = Errors{&Error{File: file, Line: line, Message: msg}}
+		return nil, v
+	}
+	if len(doc.Content) == 0 {
+		return nil, v
+	}
+	root := doc.Content[0]
+	v.node(root, t, "", "")
+	return root, v
+}
+
+// errorLine splits a yaml syntax error into its line and message
+func errorLine(err error) (int, string) {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	var line int
+	if _, e := fmt.Sscanf(msg, "line %d:", &line); e != nil {
+		return 0, msg
+	}
+	return line, strings.TrimSpace(msg[strings.Index(msg, ":")+1:])
+}
+
+// topicPattern is what GitHub accepts as a repository topic
+var topicPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,49}$`)
+
+// colorPattern is a hexadecimal color code, optionally with a leading #
+var colorPattern = regexp.MustCompile(`^#?[0-9a-fA-F]{6}$`)
+
+// secretPattern is what GitHub accepts as the name of a secret or variable
+var secretPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedSecret matches the names of secrets and variables GitHub keeps
+// for itself
+var reservedSecret = regexp.MustCompile(reservedSecretPrefix)
+
+type validator struct {
+	file string
+	errs Errors
+}
+
+func (v *validator) errorf(n *yamlv3.Node, format string, a ...interface{}) {
+	v.errs = append(v.errs, &Error{File: v.file, Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, a...)})
+}
+
+// node checks n against type t. field names the setting in messages and tag
+// holds the struct tag of the field n belongs to.
+func (v *validator) node(n *yamlv3.Node, t reflect.Type, field string, tag reflect.StructTag) {
+	if n.Kind == yamlv3.AliasNode {
+		n = n.Alias
+	}
+	if n.ShortTag() == "!!null" && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map || field == "") {
+		return
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yamlv3.MappingNode {
+			v.errorf(n, "%s must be a mapping", describe(field))
+			return
+		}
+		for i := 0; i < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			f, ok := fieldByName(t, key.Value)
+			if !ok {
+				v.errorf(key, "unknown field %s%s", join(field, key.Value), suggest(t, key.Value))
+				continue
+			}
+			v.node(value, f.Type, join(field, key.Value), f.Tag)
+		}
+
+	case reflect.Map:
+		if n.Kind != yamlv3.MappingNode {
+			v.errorf(n, "%s must be a mapping", describe(field))
+			return
+		}
+		for i := 0; i < len(n.Content); i += 2 {
+			v.node(n.Content[i+1], t.Elem(), join(field, n.Content[i].Value), tag)
+		}
+
+	case reflect.Slice:
+		if n.Kind != yamlv3.SequenceNode {
+			v.errorf(n, "%s must be a list", describe(field))
+			return
+		}
+		key := tag.Get("key")
+		seen := map[string]*yamlv3.Node{}
+		for i, item := range n.Content {
+			v.node(item, t.Elem(), fmt.Sprintf("%s[%d]", field, i), tag)
+			if key == "" {
+				continue
+			}
+			k := mappingValue(item, key)
+			if k == nil {
+				v.errorf(item, "%s[%d] has no %s", field, i, key)
+				continue
+			}
+			id := strings.ToLower(k.Value)
+			if first, ok := seen[id]; ok {
+				v.errorf(k, "duplicate %s %s in %s, first defined on line %d", key, k.Value, field, first.Line)
+				continue
+			}
+			seen[id] = k
+		}
+
+	case reflect.Bool:
+		if n.Kind != yamlv3.ScalarNode || (n.ShortTag() != "!!bool" && !yaml11Bool(n)) {
+			v.errorf(n, "%s must be true or false", describe(field))
+		}
+
+	case reflect.Int:
+		if n.Kind != yamlv3.ScalarNode || n.ShortTag() != "!!int" {
+			v.errorf(n, "%s must be a whole number", describe(field))
+			return
+		}
+		if min, ok := tag.Lookup("min"); ok {
+			i, _ := strconv.Atoi(n.Value)
+			if m, _ := strconv.Atoi(min); i < m {
+				v.errorf(n, "%s must be at least %d, got %d", describe(field), m, i)
+			}
+		}
+
+	case reflect.String:
+		if n.Kind != yamlv3.ScalarNode {
+			v.errorf(n, "%s must be a string", describe(field))
+			return
+		}
+		if enum, ok := tag.Lookup("enum"); ok && !contains(strings.Split(enum, ","), n.Value) {
+			v.errorf(n, "%s must be one of %s, got %s", describe(field), strings.Replace(enum, ",", ", ", -1), n.Value)
+		}
+		switch tag.Get("format") {
+		case "regexp":
+			if _, err := regexp.Compile(n.Value); err != nil {
+				v.errorf(n, "%s is not a valid regular expression: %s", describe(field), err)
+			}
+		case "topic":
+			if !topicPattern.MatchString(n.Value) {
+				v.errorf(n, "%s must be lowercase letters, numbers and hyphens, start with a letter or number and be at most 50 characters, got %s", describe(field), n.Value)
+			}
+		case "color":
+			if !colorPattern.MatchString(n.Value) {
+				v.errorf(n, "%s must be a hexadecimal color code like d73a4a, got %s", describe(field), n.Value)
+			}
+		case "secret":
+			if !secretPattern.MatchString(n.Value) || reservedSecret.MatchString(n.Value) {
+				v.errorf(n, "%s must be letters, numbers and underscores, not start with a number or GITHUB_, got %s", describe(field), n.Value)
+			}
+		case "glob":
+			if _, err := path.Match(n.Value, ""); err != nil {
+				v.errorf(n, "%s is not a valid pattern: %s", describe(field), err)
+			}
+		}
+	}
+}
+
+// checkTargets reports a file that names both a single repository and a
+// selector, or neither
+func checkTargets(file string, root *yamlv3.Node, c C) Errors {
+	switch {
+	case c.Repository.Name != "" && c.Repositories != nil:
+		e := &Error{File: file, Message: "set either repository.name or repositories, not both"}
+		if n := mappingValue(root, "repositories"); n != nil {
+			e.Line, e.Column = n.Line, n.Column
+		}
+		return Errors{e}
+	case c.Repository.Name == "" && c.Repositories == nil:
+		return Errors{{File: file, Message: "repository.name or repositories must be set"}}
+	}
+	return nil
+}
+
+// yaml11Bool reports whether n is a plain scalar like yes or off that YAML 1.1,
+// and so the decoder used by Read, takes as a boolean
+func yaml11Bool(n *yamlv3.Node) bool {
+	if n.Style != 0 {
+		return false
+	}
+	return contains([]string{"y", "yes", "on", "n", "no", "off"}, strings.ToLower(n.Value))
+}
+
+// checkBranches reports settings that conflict within a branch protection
+// rule once the file is merged with the defaults. Positions are taken from
+// the file when the branch is defined there.
+func checkBranches(file string, root *yamlv3.Node, c C) Errors {
+	var errs Errors
+	for _, b := range c.Branches {
+		if b.RequiresStrictStatusChecks == nil || !*b.RequiresStrictStatusChecks {
+			continue
+		}
+		if b.RequiresStatusChecks != nil && *b.RequiresStatusChecks && (b.RequiredStatusCheckContexts == nil || len(b.RequiredStatusCheckContexts) > 0) {
+			continue
+		}
+		e := &Error{File: file, Message: fmt.Sprintf("branches: %s sets requiresStrictStatusChecks without status checks, set requiresStatusChecks: true and list requiredStatusCheckContexts", b.Name)}
+		if n := listItem(root, "branches", "name", b.Name); n != nil {
+			if strict := mappingValue(n, "requiresStrictStatusChecks"); strict != nil {
+				n = strict
+			}
+			e.Line, e.Column = n.Line, n.Column
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// checkEnvironments reports branch patterns of an environment that does not
+// restrict deployments to custom branches
+func checkEnvironments(file string, root *yamlv3.Node, c C) Errors {
+	var errs Errors
+	for _, env := range c.Environments {
+		if len(env.BranchPatterns) == 0 || (env.DeploymentBranches != nil && *env.DeploymentBranches == "custom") {
+			continue
+		}
+		e := &Error{File: file, Message: fmt.Sprintf("environments: %s lists branch_patterns, set deployment_branches: custom", env.Name)}
+		if n := listItem(root, "environments", "name", env.Name); n != nil {
+			if patterns := mappingValue(n, "branch_patterns"); patterns != nil {
+				n = patterns
+			}
+			e.Line, e.Column = n.Line, n.Column
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// checkRulesets reports bypass actors that are not exactly one of a team,
+// role, app or the deploy keys
+func checkRulesets(file string, root *yamlv3.Node, c C) Errors {
+	var errs Errors
+	for _, r := range c.Rulesets {
+		for i, a := range r.BypassActors {
+			n := 0
+			for _, set := range []bool{a.Team != "", a.Role != nil, a.AppID != nil, a.DeployKeys != nil && *a.DeployKeys} {
+				if set {
+					n++
+				}
+			}
+			if n == 1 {
+				continue
+			}
+			e := &Error{File: file, Message: fmt.Sprintf("rulesets: %s: bypass_actors[%d] must set exactly one of team, role, app_id and deploy_keys: true", r.Name, i)}
+			if item := listItem(root, "rulesets", "name", r.Name); item != nil {
+				if actors := mappingValue(item, "bypass_actors"); actors != nil && actors.Kind == yamlv3.SequenceNode && i < len(actors.Content) {
+					item = actors.Content[i]
+				}
+				e.Line, e.Column = item.Line, item.Column
+			}
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// listItem returns the entry of a top level list whose key has the given value
+func listItem(root *yamlv3.Node, list string, key string, value string) *yamlv3.Node {
+	items := mappingValue(root, list)
+	if items == nil || items.Kind != yamlv3.SequenceNode {
+		return nil
+	}
+	for _, item := range items.Content {
+		if n := mappingValue(item, key); n != nil && n.Value == value {
+			return item
+		}
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(n *yamlv3.Node, key string) *yamlv3.Node {
+	if n == nil || n.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// fieldByName returns the field of struct t with the given yaml name
+func fieldByName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if yamlName(t.Field(i)) == name {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func yamlName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("yaml"), ",")[0]
+}
+
+// suggest returns a hint naming the field of t closest to a misspelt name
+func suggest(t reflect.Type, name string) string {
+	best, bestDist := "", 4
+	for i := 0; i < t.NumField(); i++ {
+		f := yamlName(t.Field(i))
+		if d := distance(strings.ToLower(name), strings.ToLower(f)); d < bestDist {
+			best, bestDist = f, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s?", best)
+}
+
+// distance is the Levenshtein distance between a and b
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(a int, b ...int) int {
+	for _, v := range b {
+		if v < a {
+			a = v
+		}
+	}
+	return a
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func describe(path string) string {
+	if path == "" {
+		return "the config"
+	}
+	return path
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
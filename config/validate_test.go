@@ -0,0 +1,176 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		errs   []string
+	}{
+		{
+			name: "valid",
+			config: `
+repository:
+  name: r
+  private: yes
+collaborators:
+  - username: dev
+    permission: push
+labels:
+  - name: bug
+    color: "#d73a4a"
+`,
+		},
+		{
+			name:   "misspelt field",
+			config: "repository:\n  name: r\nbranches:\n  - name: main\n    requiresAprovingReviews: true\n",
+			errs:   []string{"f.yaml:5:5: unknown field branches[0].requiresAprovingReviews, did you mean requiresApprovingReviews?"},
+		},
+		{
+			name:   "enum",
+			config: "collaborators:\n  - username: dev\n    permission: maintian\n",
+			errs:   []string{"f.yaml:3:17: collaborators[0].permission must be one of pull, triage, push, maintain, admin, got maintian"},
+		},
+		{
+			name:   "wrong types",
+			config: "repository:\n  private: maybe\nteams: platform\nbranches:\n  - name: main\n    requiredApprovingReviewCount: two\n",
+			errs: []string{
+				"f.yaml:2:12: repository.private must be true or false",
+				"f.yaml:3:8: teams must be a list",
+				"f.yaml:6:35: branches[0].requiredApprovingReviewCount must be a whole number",
+			},
+		},
+		{
+			name:   "min",
+			config: "branches:\n  - name: main\n    requiredApprovingReviewCount: -1\n",
+			errs:   []string{"f.yaml:3:35: branches[0].requiredApprovingReviewCount must be at least 0, got -1"},
+		},
+		{
+			name:   "duplicate keys ignore case",
+			config: "labels:\n  - name: bug\n  - name: Bug\n  - color: ffffff\n",
+			errs: []string{
+				"f.yaml:3:11: duplicate name Bug in labels, first defined on line 2",
+				"f.yaml:4:5: labels[2] has no name",
+			},
+		},
+		{
+			name:   "formats",
+			config: "repository:\n  topics: [Go]\nlabels:\n  - name: bug\n    color: red\nsecrets:\n  - name: GITHUB_TOKEN\nrepositories:\n  regexes: ['svc-(']\n",
+			errs: []string{
+				"f.yaml:2:12: repository.topics[0] must be lowercase letters, numbers and hyphens, start with a letter or number and be at most 50 characters, got Go",
+				"f.yaml:5:12: labels[0].color must be a hexadecimal color code like d73a4a, got red",
+				"f.yaml:7:11: secrets[0].name must be letters, numbers and underscores, not start with a number or GITHUB_, got GITHUB_TOKEN",
+				"f.yaml:9:13: repositories.regexes[0] is not a valid regular expression: error parsing regexp: missing closing ): `svc-(`",
+			},
+		},
+		{
+			name:   "merge",
+			config: "merge:\n  teams: union\n  topics: append\n",
+			errs: []string{
+				"f.yaml:2:10: merge.teams must be one of keyed, append, replace, got union",
+				"f.yaml:3:3: merge: topics can not be merged, only branches, collaborators, deploy_keys, environments, labels, rulesets, secrets, teams, variables, webhooks",
+			},
+		},
+		{
+			name:   "syntax error",
+			config: "repository:\n  name: r\n name: s\n",
+			errs:   []string{"f.yaml:2: did not find expected key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate("f.yaml", []byte(tt.config))
+			var got []string
+			if err != nil {
+				got = strings.Split(err.Error(), "\n")
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.errs, "\n") {
+				t.Errorf("errors\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.errs, "\n"))
+			}
+		})
+	}
+}
+
+// TestReadChecks covers the checks Read makes once a file is merged with
+// the defaults
+func TestReadChecks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		config   string
+		defaults string
+		errs     []string
+	}{
+		{
+			name:   "no target",
+			config: "labels: []\n",
+			errs:   []string{": repository.name or repositories must be set"},
+		},
+		{
+			name:   "both targets",
+			config: "repository:\n  name: r\nrepositories:\n  globs: [svc-*]\n",
+			errs:   []string{":4:3: set either repository.name or repositories, not both"},
+		},
+		{
+			name:     "strict without status checks from the defaults",
+			config:   "repository:\n  name: r\n",
+			defaults: "branches:\n  - name: main\n    requiresStrictStatusChecks: true\n",
+			errs:     []string{": branches: main sets requiresStrictStatusChecks without status checks"},
+		},
+		{
+			name:   "strict with an empty list of status checks",
+			config: "repository:\n  name: r\nbranches:\n  - name: main\n    requiresStatusChecks: true\n    requiresStrictStatusChecks: true\n    requiredStatusCheckContexts: []\n",
+			errs:   []string{":6:33: branches: main sets requiresStrictStatusChecks without status checks"},
+		},
+		{
+			name:   "strict with status checks",
+			config: "repository:\n  name: r\nbranches:\n  - name: main\n    requiresStatusChecks: true\n    requiresStrictStatusChecks: true\n    requiredStatusCheckContexts: [ci]\n",
+		},
+		{
+			name:   "branch patterns without custom branches",
+			config: "repository:\n  name: r\nenvironments:\n  - name: production\n    branch_patterns: [main]\n",
+			errs:   []string{":5:22: environments: production lists branch_patterns, set deployment_branches: custom"},
+		},
+		{
+			name:   "bypass actor setting two actors",
+			config: "repository:\n  name: r\nrulesets:\n  - name: main\n    bypass_actors:\n      - team: platform\n        role: admin\n      - deploy_keys: false\n",
+			errs: []string{
+				":6:9: rulesets: main: bypass_actors[0] must set exactly one of team, role, app_id and deploy_keys: true",
+				":8:9: rulesets: main: bypass_actors[1] must set exactly one of team, role, app_id and deploy_keys: true",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, strings.Replace(tt.name, " ", "-", -1)+".yaml")
+			if err := ioutil.WriteFile(file, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Read(file, []byte(tt.defaults))
+			var got []string
+			if err != nil {
+				got = strings.Split(err.Error(), "\n")
+			}
+			if len(got) != len(tt.errs) {
+				t.Fatalf("errors\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.errs, "\n"))
+			}
+			for j := range got {
+				if !strings.HasPrefix(got[j], file+tt.errs[j]) {
+					t.Errorf("error %s, want %s%s", got[j], file, tt.errs[j])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package context
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mkrakowitzer/ghsettings/api"
+)
+
+// refreshBefore is how long before it expires an installation token is renewed
+const refreshBefore = 5 * time.Minute
+
+// AppCredentials identify a GitHub App installation to authenticate as
+type AppCredentials struct {
+	AppID      int64
+	PrivateKey []byte
+
+	// InstallationID is looked up from Org when it is zero
+	InstallationID int64
+	Org            string
+}
+
+// NewForApp initializes a Context that authenticates as a GitHub App
+// installation. Installation tokens are requested on first use and renewed
+// shortly before they expire.
+func NewForApp(hostname string, app AppCredentials) Context {
+	if hostname == "" {
+		hostname = DefaultHostname
+	}
+	return &appContext{hostname: hostname, app: app}
+}
+
+// A Context implementation that exchanges a GitHub App JWT for installation tokens
+type appContext struct {
+	hostname string
+	app      AppCredentials
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func (c *appContext) Hostname() string {
+	return c.hostname
+}
+
+func (c *appContext) AuthToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Until(c.expiresAt) > refreshBefore {
+		return c.token, nil
+	}
+
+	jwt, err := appJWT(c.app.AppID, c.app.PrivateKey, time.Now())
+	if err != nil {
+		return "", err
+	}
+	client := api.NewClientForHost(c.hostname,
+		api.AddHeader("Authorization", fmt.Sprintf("Bearer %s", jwt)),
+		api.AddHeader("Accept", "application/vnd.github.v3+json"),
+	)
+
+	if c.app.InstallationID == 0 {
+		var installation struct {
+			ID int64 `json:"id"`
+		}
+		path := fmt.Sprintf("orgs/%s/installation", c.app.Org)
+		if err := client.REST("GET", path, &bytes.Buffer{}, &installation); err != nil {
+			return "", fmt.Errorf("looking up installation of app %d on %s: %s", c.app.AppID, c.app.Org, err)
+		}
+		c.app.InstallationID = installation.ID
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	path := fmt.Sprintf("app/installations/%d/access_tokens", c.app.InstallationID)
+	if err := client.REST("POST", path, &bytes.Buffer{}, &result); err != nil {
+		return "", fmt.Errorf("requesting installation token: %s", err)
+	}
+
+	c.token = result.Token
+	c.expiresAt = result.ExpiresAt
+	return c.token, nil
+}
+
+// appJWT returns a JWT signed with the app private key, valid for 9 minutes
+func appJWT(appID int64, privateKey []byte, now time.Time) (string, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		// backdated to allow for clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("app private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing app private key: %s", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("app private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
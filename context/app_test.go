@@ -0,0 +1,125 @@
+package context
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	for _, block := range []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		jwt, err := appJWT(42, pem.EncodeToMemory(block), now)
+		if err != nil {
+			t.Fatalf("%s: %s", block.Type, err)
+		}
+		parts := strings.Split(jwt, ".")
+		if len(parts) != 3 {
+			t.Fatalf("%s: JWT %s does not have three parts", block.Type, jwt)
+		}
+
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], sig); err != nil {
+			t.Errorf("%s: signature does not verify: %s", block.Type, err)
+		}
+
+		var header map[string]string
+		var claims map[string]int64
+		for i, v := range []interface{}{&header, &claims} {
+			data, err := base64.RawURLEncoding.DecodeString(parts[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(data, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if header["alg"] != "RS256" || header["typ"] != "JWT" {
+			t.Errorf("%s: header %v, want RS256 JWT", block.Type, header)
+		}
+		want := map[string]int64{"iat": now.Unix() - 60, "exp": now.Unix() + 540, "iss": 42}
+		if fmt.Sprint(claims) != fmt.Sprint(want) {
+			t.Errorf("%s: claims %v, want %v", block.Type, claims, want)
+		}
+	}
+
+	for _, data := range []string{"not a key", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("junk")}))} {
+		if _, err := appJWT(42, []byte(data), now); err == nil {
+			t.Errorf("signed with the invalid key %q", data)
+		}
+	}
+}
+
+// TestAppAuthToken checks that an installation token is reused until it is
+// about to expire
+func TestAppAuthToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first token expires within refreshBefore, the second does not
+	expiries := []time.Duration{refreshBefore - time.Minute, time.Hour}
+	var lookups, issued int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			t.Errorf("%s %s sent without the app JWT", r.Method, r.URL.Path)
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v3/orgs/o/installation":
+			lookups++
+			fmt.Fprint(w, `{"id":7}`)
+		case "POST /api/v3/app/installations/7/access_tokens":
+			expiresAt := time.Now().Add(expiries[issued]).UTC().Format(time.RFC3339)
+			issued++
+			fmt.Fprintf(w, `{"token":"t%d","expires_at":"%s"}`, issued, expiresAt)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := NewForApp(server.URL, AppCredentials{
+		AppID:      42,
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		Org:        "o",
+	})
+	for i, want := range []string{"t1", "t2", "t2"} {
+		got, err := c.AuthToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("token %d is %s, want %s", i+1, got, want)
+		}
+	}
+	if lookups != 1 || issued != 2 {
+		t.Errorf("looked up the installation %d times and issued %d tokens, want 1 and 2", lookups, issued)
+	}
+}
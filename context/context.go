@@ -1,28 +1,71 @@
 package context
 
-import "os"
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultHostname is the host used when none is configured
+const DefaultHostname = "github.com"
 
 // Context represents the interface for querying information about the current environment
 type Context interface {
+	Hostname() string
 	AuthToken() (string, error)
 }
 
 // New initializes a Context that reads from the filesystem
 func New() Context {
-	return &fsContext{}
+	return NewForHost(DefaultHostname)
+}
+
+// NewForHost initializes a Context for a github.com or GitHub Enterprise
+// Server host. The token is taken from the first of sources that has one,
+// DefaultTokenSources if none are given.
+func NewForHost(hostname string, sources ...TokenSource) Context {
+	if hostname == "" {
+		hostname = DefaultHostname
+	}
+	if len(sources) == 0 {
+		sources = DefaultTokenSources()
+	}
+	return &fsContext{hostname: hostname, sources: sources}
 }
 
 // A Context implementation that queries the filesystem
 type fsContext struct {
+	hostname string
+	sources  []TokenSource
+
+	mu        sync.Mutex
 	authToken string
 }
 
+func (c *fsContext) Hostname() string {
+	return c.hostname
+}
+
 func (c *fsContext) AuthToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.authToken != "" {
 		return c.authToken, nil
 	}
 
-	token := os.Getenv("MU_GITHUB_TOKEN")
+	tried := make([]string, 0, len(c.sources))
+	for _, s := range c.sources {
+		token, err := s.Token(c.hostname)
+		if err != nil {
+			return "", err
+		}
+		if token != "" {
+			c.authToken = token
+			return token, nil
+		}
+		tried = append(tried, s.Name())
+	}
 
-	return token, nil
+	return "", fmt.Errorf("no token found for %s, tried: %s", c.hostname, strings.Join(tried, "; "))
 }
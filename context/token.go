@@ -0,0 +1,171 @@
+package context
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"gopkg.in/yaml.v2"
+)
+
+// TokenSource looks up the token for a host. Token returns an empty string
+// when the source has no token for the host.
+type TokenSource interface {
+	Name() string
+	Token(hostname string) (string, error)
+}
+
+type tokenSource struct {
+	name  string
+	token func(hostname string) (string, error)
+}
+
+func (s tokenSource) Name() string {
+	return s.name
+}
+
+func (s tokenSource) Token(hostname string) (string, error) {
+	return s.token(hostname)
+}
+
+// DefaultTokenSources are the sources tried when no others are given:
+// environment variables, the gh CLI hosts file and ~/.netrc
+func DefaultTokenSources() []TokenSource {
+	return []TokenSource{EnvToken(), GhHostsFile(""), Netrc("")}
+}
+
+// StaticToken returns token for every host, e.g. one given as a flag
+func StaticToken(name string, token string) TokenSource {
+	return tokenSource{name: name, token: func(string) (string, error) {
+		return token, nil
+	}}
+}
+
+// HostTokens returns the token from a map of lower case host names to tokens
+func HostTokens(name string, tokens map[string]string) TokenSource {
+	return tokenSource{name: name, token: func(hostname string) (string, error) {
+		return tokens[strings.ToLower(hostname)], nil
+	}}
+}
+
+// EnvToken reads MU_GITHUB_TOKEN, GH_TOKEN or GITHUB_TOKEN. For GitHub
+// Enterprise Server hosts GH_ENTERPRISE_TOKEN and GITHUB_ENTERPRISE_TOKEN are
+// tried first.
+func EnvToken() TokenSource {
+	return tokenSource{name: "MU_GITHUB_TOKEN, GH_TOKEN, GITHUB_TOKEN", token: func(hostname string) (string, error) {
+		vars := []string{"MU_GITHUB_TOKEN", "GH_TOKEN", "GITHUB_TOKEN"}
+		if hostname != DefaultHostname {
+			vars = append([]string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}, vars...)
+		}
+		for _, v := range vars {
+			if token := os.Getenv(v); token != "" {
+				return token, nil
+			}
+		}
+		return "", nil
+	}}
+}
+
+// GhHostsFile reads the oauth_token written by `gh auth login`. When path is
+// empty the gh config directory is used.
+func GhHostsFile(path string) TokenSource {
+	if path == "" {
+		path = filepath.Join(ghConfigDir(), "hosts.yml")
+	}
+	return tokenSource{name: path, token: func(hostname string) (string, error) {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		hosts := map[string]struct {
+			OAuthToken string `yaml:"oauth_token"`
+		}{}
+		if err := yaml.Unmarshal(data, &hosts); err != nil {
+			return "", fmt.Errorf("%s: %s", path, err)
+		}
+		return hosts[hostname].OAuthToken, nil
+	}}
+}
+
+func ghConfigDir() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh")
+	}
+	home, _ := homedir.Dir()
+	return filepath.Join(home, ".config", "gh")
+}
+
+// Netrc reads the password of the machine matching the host or its API host,
+// or else that of the default entry. When path is empty NETRC or ~/.netrc is
+// used.
+func Netrc(path string) TokenSource {
+	if path == "" {
+		path = os.Getenv("NETRC")
+	}
+	if path == "" {
+		home, _ := homedir.Dir()
+		path = filepath.Join(home, ".netrc")
+	}
+	return tokenSource{name: path, token: func(hostname string) (string, error) {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		scanner.Split(bufio.ScanWords)
+		machine, isDefault, fallback := "", false, ""
+		for scanner.Scan() {
+			switch scanner.Text() {
+			case "machine":
+				isDefault = false
+				if scanner.Scan() {
+					machine = scanner.Text()
+				}
+			case "default":
+				machine, isDefault = "", true
+			case "password":
+				if !scanner.Scan() {
+					break
+				}
+				if isDefault {
+					fallback = scanner.Text()
+				} else if machine == hostname || machine == "api."+hostname {
+					return scanner.Text(), nil
+				}
+			}
+		}
+		return fallback, scanner.Err()
+	}}
+}
+
+// CommandToken runs a credential helper command through the shell and uses
+// its output as the token. The host is passed in GHSETTINGS_HOSTNAME.
+func CommandToken(command string) TokenSource {
+	return tokenSource{name: "token command", token: func(hostname string) (string, error) {
+		if command == "" {
+			return "", nil
+		}
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Env = append(os.Environ(), "GHSETTINGS_HOSTNAME="+hostname)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("token command '%s': %s", command, err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}}
+}
@@ -0,0 +1,48 @@
+package context
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNetrc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		netrc    string
+		hostname string
+		want     string
+	}{
+		{"machine", "machine github.com login me password t1\n", "github.com", "t1"},
+		{"api host", "machine api.github.com\n  login me\n  password t2\n", "github.com", "t2"},
+		{"other host", "machine gitlab.com login me password t3\n", "github.com", ""},
+		{"default entry", "machine gitlab.com login me password t3\ndefault login me password t4\n", "github.com", "t4"},
+		{"machine wins over an earlier default", "default login me password t4\nmachine github.com login me password t1\n", "github.com", "t1"},
+		{"default does not take the machine before it", "machine github.com login me\ndefault password t4\n", "ghe.example.com", "t4"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "netrc")
+		if err := ioutil.WriteFile(path, []byte(tt.netrc), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Netrc(path).Token(tt.hostname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: token %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	got, err := Netrc(filepath.Join(dir, "missing")).Token("github.com")
+	if got != "" || err != nil {
+		t.Errorf("missing netrc returned %q, %v", got, err)
+	}
+}